@@ -0,0 +1,133 @@
+package dotmtx
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"runtime"
+)
+
+// Animation is a sequence of frames drawn on demand, so that only
+// the frame being encoded needs to be kept in memory.
+type Animation struct {
+	Width   int
+	Height  int
+	Palette color.Palette
+	// Delay holds the duration of each frame in centiseconds
+	Delay []int
+	// LoopCount follows the conventions of gif.GIF
+	LoopCount int
+
+	// draw draws the i-th frame into a blank frame
+	draw func(frame *image.Paletted, i int)
+}
+
+// Len returns the number of frames.
+func (a *Animation) Len() int {
+	return len(a.Delay)
+}
+
+// Frame draws the i-th frame.
+func (a *Animation) Frame(i int) *image.Paletted {
+	frame := newFrame(a.Width, a.Height, a.Palette)
+	a.draw(frame, i)
+	return frame
+}
+
+// GIF draws all frames at once; it gives up with
+// the context error as soon as ctx is cancelled.
+func (a *Animation) GIF(ctx context.Context) (*gif.GIF, error) {
+	anim := gif.GIF{
+		Image:     make([]*image.Paletted, a.Len()),
+		Delay:     a.Delay,
+		LoopCount: a.LoopCount,
+		Disposal:  make([]byte, a.Len()),
+		Config: image.Config{
+			ColorModel: a.Palette,
+			Width:      a.Width,
+			Height:     a.Height,
+		},
+		BackgroundIndex: 0,
+	}
+
+	for i := range anim.Image {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		anim.Image[i] = a.Frame(i)
+	}
+
+	return &anim, nil
+}
+
+// encodedFrame is a frame compressed by a worker of EncodeGif.
+type encodedFrame struct {
+	data []byte
+	err  error
+}
+
+// EncodeGif writes the animation to w in GIF format. Frames are drawn
+// and compressed concurrently on all available cores, a few at a time,
+// then written in order; the output is the same as that of gif.EncodeAll.
+// It stops with the context error as soon as ctx is cancelled.
+func (a *Animation) EncodeGif(ctx context.Context, w io.Writer) error {
+	gw, err := newGifWriter(contextWriter{ctx, w}, a)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// frames in progress, in order; the capacity
+	// bounds the number of frames kept in memory
+	pending := make(chan chan encodedFrame, runtime.GOMAXPROCS(0))
+
+	go func() {
+		defer close(pending)
+
+		for i := 0; i < a.Len(); i++ {
+			result := make(chan encodedFrame, 1)
+
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+
+			go func() {
+				if err := ctx.Err(); err != nil {
+					result <- encodedFrame{nil, err}
+					return
+				}
+
+				frame := a.Frame(i)
+				data, err := gw.encodeFrame(frame, a.Delay[i])
+				releaseFrame(frame)
+
+				result <- encodedFrame{data, err}
+			}()
+		}
+	}()
+
+	for result := range pending {
+		frame := <-result
+		if frame.err != nil {
+			return frame.err
+		}
+
+		if err := gw.writeFrame(frame.data); err != nil {
+			return err
+		}
+	}
+
+	// frames stop being produced when the parent context is cancelled
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return gw.close()
+}
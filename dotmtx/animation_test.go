@@ -0,0 +1,95 @@
+package dotmtx
+
+import (
+	"bytes"
+	"context"
+	"image/gif"
+	"io"
+	"testing"
+)
+
+var encodeCases = []struct {
+	name string
+	opts Options
+}{
+	{"short", Options{Speed: 4, Width: 1, Blank: 1, Text: "HELLO WORLD"}},
+	{"static", Options{Speed: 0, Width: 2, Blank: 0.5, Text: "HELLO"}},
+	{"bounce", Options{Speed: 4, Width: 0.5, Blank: 1, Text: "HELLO WORLD", Bounce: true, Dwell: 1}},
+	{"panel", Options{Speed: 0, Width: 1, Blank: 1, Text: "HELLO WORLD HOW ARE YOU TODAY", PanelColumns: 48, PanelRows: 16}},
+	{"long", Options{Speed: 8, Width: 1, Blank: 1, Text: "THE QUICK BROWN FOX JUMPS OVER THE LAZY DOG 0123456789"}},
+}
+
+func TestEncodeGifMatchesEncodeAll(t *testing.T) {
+	ctx := context.Background()
+
+	for _, c := range encodeCases {
+		t.Run(c.name, func(t *testing.T) {
+			anim, err := MakeAnimation(ctx, c.opts)
+			if err != nil {
+				t.Fatal("MakeAnimation: ", err)
+			}
+
+			frames, err := anim.GIF(ctx)
+			if err != nil {
+				t.Fatal("GIF: ", err)
+			}
+
+			var expected, actual bytes.Buffer
+			if err := gif.EncodeAll(&expected, frames); err != nil {
+				t.Fatal("EncodeAll: ", err)
+			}
+			if err := anim.EncodeGif(ctx, &actual); err != nil {
+				t.Fatal("EncodeGif: ", err)
+			}
+
+			if !bytes.Equal(expected.Bytes(), actual.Bytes()) {
+				t.Errorf("output differs from gif.EncodeAll (%d frames, %d bytes instead of %d)",
+					anim.Len(), actual.Len(), expected.Len())
+			}
+		})
+	}
+}
+
+// frames are drawn by both benchmarks, for a fair comparison
+
+func BenchmarkEncodeGif(b *testing.B) {
+	ctx := context.Background()
+
+	for _, c := range encodeCases {
+		b.Run(c.name, func(b *testing.B) {
+			anim, err := MakeAnimation(ctx, c.opts)
+			if err != nil {
+				b.Fatal("MakeAnimation: ", err)
+			}
+
+			for range b.N {
+				if err := anim.EncodeGif(ctx, io.Discard); err != nil {
+					b.Fatal("EncodeGif: ", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeAll(b *testing.B) {
+	ctx := context.Background()
+
+	for _, c := range encodeCases {
+		b.Run(c.name, func(b *testing.B) {
+			anim, err := MakeAnimation(ctx, c.opts)
+			if err != nil {
+				b.Fatal("MakeAnimation: ", err)
+			}
+
+			for range b.N {
+				frames, err := anim.GIF(ctx)
+				if err != nil {
+					b.Fatal("GIF: ", err)
+				}
+				if err := gif.EncodeAll(io.Discard, frames); err != nil {
+					b.Fatal("EncodeAll: ", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package dotmtx
+
+import (
+	"image"
+	"image/color"
+	"sync"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// glyph is a character rasterized once as a dot bitmap.
+type glyph struct {
+	// bounds of the bitmap relative to the origin of the glyph on the baseline
+	bounds image.Rectangle
+	// lit holds the state of each dot of bounds, row by row
+	lit     []bool
+	advance int
+}
+
+// glyphs holds the glyphs of all characters in the font
+var glyphs map[rune]*glyph
+
+// defaultGlyph is drawn for characters missing from the font, it may be nil
+var defaultGlyph *glyph
+
+// rasterize draws the glyph of r as found in Font.
+func rasterize(r rune) *glyph {
+	dr, mask, maskp, advance, ok := Font.Glyph(fixed.Point26_6{}, r)
+	if !ok {
+		return nil
+	}
+
+	g := &glyph{
+		bounds:  dr,
+		lit:     make([]bool, dr.Dx()*dr.Dy()),
+		advance: advance.Round(),
+	}
+
+	for y := 0; y < dr.Dy(); y++ {
+		for x := 0; x < dr.Dx(); x++ {
+			_, _, _, a := mask.At(maskp.X+x, maskp.Y+y).RGBA()
+			g.lit[y*dr.Dx()+x] = a != 0
+		}
+	}
+
+	return g
+}
+
+// buildAtlas rasterizes all characters of the font.
+func buildAtlas(defaultChar rune) {
+	glyphs = make(map[rune]*glyph, len(fontChars))
+	for r := range fontChars {
+		glyphs[r] = rasterize(r)
+	}
+
+	defaultGlyph = glyphs[defaultChar]
+}
+
+// drawText lights the dots of text on dst, starting with the origin
+// of the first character at (x, baseline), the same way as font.Drawer
+// would do. It returns the first and last column plus one of each rune.
+func drawText(dst *image.Paletted, x, baseline int, text string) (bounds [][2]int) {
+	const lit = 2
+
+	for _, r := range text {
+		start := x
+
+		g, ok := glyphs[r]
+		if !ok {
+			g = defaultGlyph
+		}
+
+		if g != nil {
+			origin := image.Pt(x, baseline)
+			area := g.bounds.Add(origin).Intersect(dst.Rect)
+
+			for y := area.Min.Y; y < area.Max.Y; y++ {
+				row := g.lit[(y-origin.Y-g.bounds.Min.Y)*g.bounds.Dx():]
+				for dx := area.Min.X; dx < area.Max.X; dx++ {
+					if row[dx-origin.X-g.bounds.Min.X] {
+						dst.Pix[dst.PixOffset(dx, y)] = lit
+					}
+				}
+			}
+
+			x += g.advance
+		}
+
+		bounds = append(bounds, [2]int{start, min(dst.Rect.Max.X, x)})
+	}
+
+	return
+}
+
+// dotTiles holds, for each dot state, a row of pixels of a dot
+// followed by the gap before the next one; rows of dots are drawn
+// by copying tiles.
+type dotTiles [len(palette)][]uint8
+
+// tileCache maps geometries to their tiles
+var tileCache sync.Map
+
+// tiles returns the dot tiles for the geometry.
+func (g Geometry) tiles() *dotTiles {
+	if tiles, ok := tileCache.Load(g); ok {
+		return tiles.(*dotTiles)
+	}
+
+	tiles := new(dotTiles)
+	for state := range tiles {
+		tiles[state] = make([]uint8, g.Size())
+		for i := 0; i < g.Inner; i++ {
+			tiles[state][i] = uint8(state)
+		}
+	}
+
+	actual, _ := tileCache.LoadOrStore(g, tiles)
+	return actual.(*dotTiles)
+}
+
+// framePool recycles the pixel buffers of frames
+var framePool sync.Pool
+
+// newFrame returns a blank frame, reusing a pooled buffer when possible.
+func newFrame(width, height int, p color.Palette) *image.Paletted {
+	if pix, ok := framePool.Get().(*[]uint8); ok && cap(*pix) >= width*height {
+		frame := &image.Paletted{
+			Pix:     (*pix)[:width*height],
+			Stride:  width,
+			Rect:    image.Rect(0, 0, width, height),
+			Palette: p,
+		}
+		clear(frame.Pix)
+		return frame
+	}
+
+	return image.NewPaletted(image.Rect(0, 0, width, height), p)
+}
+
+// releaseFrame returns the buffer of frame to the pool;
+// the frame must not be used afterwards.
+func releaseFrame(frame *image.Paletted) {
+	pix := frame.Pix
+	framePool.Put(&pix)
+}
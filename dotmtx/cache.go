@@ -0,0 +1,95 @@
+package dotmtx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RendererVersion must change whenever the renderer output changes
+// for the same parameters, so that cached renders are invalidated.
+const RendererVersion = "4"
+
+// Cache lifetimes in seconds. Renders are fully determined by their
+// parameters and can be cached forever; error responses may change
+// along with validation rules and are only cached briefly.
+const (
+	renderMaxAge = 365 * 24 * 60 * 60
+	errorMaxAge  = 60
+)
+
+// allowRead replies with 405 and returns false
+// unless the request method is GET or HEAD.
+func allowRead(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+// renderETag returns a strong ETag for the output of the given kind
+// (gif, jpeg, a video profile...) rendered from opts. It only depends on the
+// canonical parameters and the renderer version, so that every server
+// computes the same tag; no Vary header is needed as the output
+// does not depend on request headers.
+func renderETag(kind string, opts Options) string {
+	sum := sha256.Sum256([]byte(RendererVersion + "\x00" + kind + "\x00" + opts.Values().Encode()))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value
+// matches etag, using the weak comparison required by RFC 9110.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCache sets the caching headers of a render response with the
+// given status. It replies with 304 and returns true when the client
+// already holds the current version, so that nothing needs rendering.
+func checkCache(w http.ResponseWriter, r *http.Request, kind string, opts Options, status int) bool {
+	if status != http.StatusOK {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", errorMaxAge))
+		return false
+	}
+
+	etag := renderETag(kind, opts)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", renderMaxAge))
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+// serveRender writes content with the given status; successful
+// responses go through http.ServeContent, which takes care of HEAD,
+// conditional and range requests. The Content-Type must be set already.
+func serveRender(w http.ResponseWriter, r *http.Request, status int, content io.ReadSeeker) error {
+	if status == http.StatusOK {
+		http.ServeContent(w, r, "", time.Time{}, content)
+		return nil
+	}
+
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+
+	_, err := io.Copy(w, content)
+	return err
+}
@@ -0,0 +1,29 @@
+package dotmtx
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"3-0123456789abcdef"`
+
+	cases := []struct {
+		ifNoneMatch string
+		expected    bool
+	}{
+		{`"3-0123456789abcdef"`, true},
+		{`W/"3-0123456789abcdef"`, true},
+		{`*`, true},
+		{`"a", "3-0123456789abcdef"`, true},
+		{`"a",W/"3-0123456789abcdef" , "b"`, true},
+		{`"2-0123456789abcdef"`, false},
+		{`3-0123456789abcdef`, false},
+		{`"a", "b"`, false},
+		{`w/"3-0123456789abcdef"`, false},
+		{``, false},
+	}
+
+	for _, c := range cases {
+		if actual := etagMatches(c.ifNoneMatch, etag); actual != c.expected {
+			t.Errorf("etagMatches(%q) = %v; expected %v", c.ifNoneMatch, actual, c.expected)
+		}
+	}
+}
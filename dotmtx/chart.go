@@ -0,0 +1,44 @@
+package dotmtx
+
+import (
+	"image"
+)
+
+// MakeChart draws a bar chart of the given values as a dot matrix
+// display with the given number of rows. Each value takes one column,
+// bars are separated by a column of unlit dots.
+func MakeChart(values []int, rows int) *image.Paletted {
+	columns := max(1, 2*len(values)-1)
+
+	highest := 0
+	for _, value := range values {
+		highest = max(highest, value)
+	}
+
+	dotMatrix := image.NewPaletted(image.Rect(0, 0, columns, rows), palette[:])
+	for i := range dotMatrix.Pix {
+		dotMatrix.Pix[i] = 1
+	}
+
+	for i, value := range values {
+		if value <= 0 {
+			continue
+		}
+
+		// nonzero values always get at least one dot
+		height := max(1, value*rows/max(1, highest))
+		for y := rows - height; y < rows; y++ {
+			dotMatrix.Pix[y*dotMatrix.Stride+2*i] = 2
+		}
+	}
+
+	dots := DefaultGeometry
+	img := image.NewPaletted(
+		image.Rect(0, 0, int(dots.Width(float64(columns))), int(dots.Width(float64(rows)))),
+		palette[:],
+	)
+
+	drawDots(img, dotMatrix, dots, func(x int) int { return x })
+
+	return img
+}
@@ -0,0 +1,48 @@
+package dotmtx
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Color selects the color of lit dots.
+type Color int
+
+const (
+	ColorAmber Color = iota
+	ColorRed
+	ColorGreen
+	ColorBlue
+	ColorWhite
+)
+
+var colorNames = [...]string{"amber", "red", "green", "blue", "white"}
+
+var litColors = [...]color.Color{
+	palette[2],
+	color.RGBA{255, 40, 20, 255},
+	color.RGBA{60, 255, 60, 255},
+	color.RGBA{40, 140, 255, 255},
+	color.RGBA{240, 240, 240, 255},
+}
+
+func (c Color) String() string {
+	if c < 0 || int(c) >= len(colorNames) {
+		return fmt.Sprintf("Color(%d)", int(c))
+	}
+	return colorNames[c]
+}
+
+// Next returns the color following c, wrapping around to the first one.
+func (c Color) Next() Color {
+	return (c + 1) % Color(len(colorNames))
+}
+
+// palette returns the palette of images drawn with color c;
+// indices have the same meaning as in the default palette.
+func (c Color) palette() color.Palette {
+	if c <= ColorAmber || int(c) >= len(litColors) {
+		return palette[:]
+	}
+	return color.Palette{palette[0], palette[1], litColors[c]}
+}
@@ -0,0 +1,71 @@
+package dotmtx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"petbots.fbbdev.it/dotmtxbot/log"
+)
+
+// renderTimeout is the time allowed to render a response, zero means no limit
+var renderTimeout = 15 * time.Second
+
+func init() {
+	if value := os.Getenv("DOTMTXBOT_RENDER_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			log.ErrorLogger.Print("time: ", err)
+			log.FatalLogger.Fatal("invalid render timeout")
+		}
+
+		renderTimeout = timeout
+	}
+}
+
+// renderContext returns the context for rendering the response to r:
+// it is cancelled when the client goes away or the render deadline expires.
+func renderContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if renderTimeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+
+	return context.WithTimeout(r.Context(), renderTimeout)
+}
+
+// renderCancelled handles errors caused by the cancellation of the render
+// context; it reports false for any other error, which the caller must handle.
+func renderCancelled(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.WarningLogger.Printf("render deadline exceeded (url=%v)", r.URL)
+		w.Header().Del("ETag")
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, "Render deadline exceeded", http.StatusServiceUnavailable)
+		return true
+	case errors.Is(err, context.Canceled):
+		// the client went away, nobody will read the response
+		log.InfoLogger.Printf("render cancelled by client (url=%v)", r.URL)
+		return true
+	default:
+		return false
+	}
+}
+
+// contextWriter fails writes after its context has been cancelled,
+// so that encoders writing to it stop early.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return cw.w.Write(p)
+}
@@ -0,0 +1,87 @@
+package dotmtx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"petbots.fbbdev.it/dotmtxbot/i18n"
+)
+
+var ErrUnknownOption = errors.New("unknown option")
+var ErrInvalidOption = errors.New("invalid option value")
+
+// OptionError records an unknown or invalid parameter.
+type OptionError struct {
+	Name string
+	Err  error
+}
+
+func (e *OptionError) Error() string {
+	return e.Err.Error() + ": " + e.Name
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+var ErrInvalidSpeed = &OptionError{"speed", ErrInvalidOption}
+var ErrInvalidWidth = &OptionError{"width", ErrInvalidOption}
+var ErrInvalidBlank = &OptionError{"blank", ErrInvalidOption}
+var ErrTextTooLong = &OptionError{"text", ErrInvalidOption}
+
+// UnsupportedCharError records a character missing from the font.
+type UnsupportedCharError struct {
+	Char rune
+}
+
+func (e *UnsupportedCharError) Error() string {
+	return fmt.Sprintf("unsupported character %U", e.Char)
+}
+
+func (e *UnsupportedCharError) Unwrap() error {
+	return ErrInvalidOption
+}
+
+// ErrTextRefused is returned by Validate when TextFilter refuses the text.
+var ErrTextRefused = errors.New("text refused")
+
+var errTooBig = errors.New("animation too big")
+
+// ErrorReason returns a short description of a validation error
+// in the given language, suitable to be displayed in an error animation.
+func ErrorReason(err error, lang string) string {
+	var optionErr *OptionError
+	var charErr *UnsupportedCharError
+
+	switch {
+	case err == errTooBig:
+		return i18n.Text(lang, i18n.ReasonTooBig)
+	case err == ErrTextRefused:
+		return i18n.Text(lang, i18n.ReasonRefused)
+	case err == ErrTextTooLong:
+		return i18n.Text(lang, i18n.ReasonTooLong, MaxChars)
+	case errors.As(err, &charErr):
+		return i18n.Text(lang, i18n.ReasonUnsupportedChar, charErr.Char)
+	case errors.As(err, &optionErr) && optionErr.Err == ErrUnknownOption:
+		return i18n.Text(lang, i18n.ReasonUnknownOption, strings.ToUpper(optionErr.Name))
+	case errors.As(err, &optionErr):
+		return i18n.Text(lang, i18n.ReasonInvalidOption, strings.ToUpper(optionErr.Name))
+	default:
+		return i18n.Text(lang, i18n.ReasonError)
+	}
+}
+
+// ErrorOptions returns the options of an animation displaying
+// the reason of the given error, with the color and in the language
+// of opts, which may be partially parsed.
+func ErrorOptions(err error, opts Options) Options {
+	return Options{
+		Speed: 0,
+		Width: 1,
+		Blank: 0,
+		Text:  ErrorReason(err, opts.Lang),
+		Color: opts.Color,
+		Lang:  opts.Lang,
+	}
+}
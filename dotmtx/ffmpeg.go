@@ -0,0 +1,304 @@
+package dotmtx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"petbots.fbbdev.it/dotmtxbot/log"
+)
+
+// ffmpeg settings, see the DOTMTXBOT_FFMPEG_* environment variables
+var (
+	ffmpegPath = "ffmpeg"
+	// it must be shorter than the render timeout,
+	// so that there is time left for the GIF fallback
+	ffmpegTimeout = 10 * time.Second
+	// limits for the child process, zero means no limit
+	ffmpegCPUSeconds = 60
+	ffmpegMemoryMiB  = 2048
+	ffmpegNice       = 10
+	// ionice class: 1 realtime, 2 best-effort, 3 idle; 0 leaves it unchanged
+	ffmpegIOClass = 3
+)
+
+// maxStderr is how much of the error output of ffmpeg is kept for logging
+const maxStderr = 4096
+
+func init() {
+	if value := os.Getenv("DOTMTXBOT_FFMPEG_PATH"); value != "" {
+		ffmpegPath = value
+	}
+
+	if value := os.Getenv("DOTMTXBOT_FFMPEG_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			log.ErrorLogger.Print("time: ", err)
+			log.FatalLogger.Fatal("invalid ffmpeg timeout")
+		}
+
+		ffmpegTimeout = timeout
+	}
+
+	for name, setting := range map[string]*int{
+		"DOTMTXBOT_FFMPEG_CPU_SECONDS": &ffmpegCPUSeconds,
+		"DOTMTXBOT_FFMPEG_MEMORY_MIB":  &ffmpegMemoryMiB,
+		"DOTMTXBOT_FFMPEG_NICE":        &ffmpegNice,
+		"DOTMTXBOT_FFMPEG_IONICE":      &ffmpegIOClass,
+	} {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				log.ErrorLogger.Print("strconv: ", err)
+				log.FatalLogger.Fatalf("invalid value for %s", name)
+			}
+
+			*setting = n
+		}
+	}
+}
+
+// FFmpegFailure classifies the ways an ffmpeg run can fail.
+type FFmpegFailure int
+
+const (
+	// FFmpegEncoderError means ffmpeg exited with an error.
+	FFmpegEncoderError FFmpegFailure = iota
+	// FFmpegTimeout means ffmpeg ran longer than the configured timeout.
+	FFmpegTimeout
+	// FFmpegKilled means ffmpeg was killed by a signal, usually
+	// because it exceeded its CPU or memory limits.
+	FFmpegKilled
+	// FFmpegOutOfMemory means ffmpeg gave up because
+	// it could not allocate memory within its limit.
+	FFmpegOutOfMemory
+)
+
+var ffmpegFailureNames = [...]string{"encoder error", "timeout", "killed", "out of memory"}
+
+func (f FFmpegFailure) String() string {
+	return ffmpegFailureNames[f]
+}
+
+// FFmpegError describes a failed ffmpeg run.
+type FFmpegError struct {
+	Failure FFmpegFailure
+	Err     error
+	// Stderr holds the last lines written by ffmpeg
+	Stderr string
+}
+
+func (e *FFmpegError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Failure, e.Err)
+}
+
+func (e *FFmpegError) Unwrap() error {
+	return e.Err
+}
+
+// tailBuffer keeps the last bytes written to it.
+type tailBuffer struct {
+	buf       []byte
+	truncated bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > maxStderr {
+		t.buf = t.buf[len(t.buf)-maxStderr:]
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	s := strings.TrimSpace(string(t.buf))
+	if t.truncated {
+		s = "..." + s
+	}
+	return s
+}
+
+// ffmpegWrappers is the command line prefix applying the configured
+// priorities and limits to ffmpeg, it is set by ProbeFFmpeg
+var ffmpegWrappers []string
+
+// probeWrappers builds the command line prefix running ffmpeg
+// in nice, ionice and prlimit as configured; missing tools are
+// reported and skipped.
+func probeWrappers() []string {
+	var wrappers []string
+
+	available := func(name string) bool {
+		if _, err := exec.LookPath(name); err != nil {
+			log.WarningLogger.Printf("%s not found in PATH, ffmpeg will run without it", name)
+			return false
+		}
+		return true
+	}
+
+	if ffmpegNice > 0 && available("nice") {
+		wrappers = append(wrappers, "nice", "-n", strconv.Itoa(ffmpegNice))
+	}
+
+	if ffmpegIOClass > 0 && available("ionice") {
+		wrappers = append(wrappers, "ionice", "-c", strconv.Itoa(ffmpegIOClass))
+	}
+
+	if (ffmpegCPUSeconds > 0 || ffmpegMemoryMiB > 0) && available("prlimit") {
+		wrappers = append(wrappers, "prlimit")
+		if ffmpegCPUSeconds > 0 {
+			wrappers = append(wrappers, "--cpu="+strconv.Itoa(ffmpegCPUSeconds))
+		}
+		if ffmpegMemoryMiB > 0 {
+			wrappers = append(wrappers, "--as="+strconv.Itoa(ffmpegMemoryMiB<<20))
+		}
+		wrappers = append(wrappers, "--")
+	}
+
+	return wrappers
+}
+
+// ffmpegCommand returns the command running ffmpeg with args
+// within the wrappers found by ProbeFFmpeg.
+func ffmpegCommand(ctx context.Context, args ...string) *exec.Cmd {
+	args = append(append(slices.Clip(ffmpegWrappers), ffmpegPath), args...)
+	return exec.CommandContext(ctx, args[0], args[1:]...)
+}
+
+// outOfMemory reports whether the error output of ffmpeg tells
+// that an allocation failed, as happens when the memory limit is hit.
+func outOfMemory(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	return strings.Contains(stderr, "cannot allocate memory") ||
+		strings.Contains(stderr, "out of memory") ||
+		strings.Contains(stderr, "failed to allocate")
+}
+
+// runFFmpeg runs ffmpeg with the given arguments and input, within
+// the configured timeout and resource limits. When ctx is cancelled,
+// it returns the context error; other failures, including the expiry
+// of the deadline of ctx, are reported as an *FFmpegError.
+func runFFmpeg(ctx context.Context, args []string, stdin io.Reader) error {
+	runCtx := ctx
+	if ffmpegTimeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, ffmpegTimeout)
+		defer cancel()
+	}
+
+	var stderr tailBuffer
+
+	cmd := ffmpegCommand(runCtx, args...)
+	cmd.Stdin = stdin
+	cmd.Stderr = &stderr
+	// do not wait for stray children holding stderr open after a kill
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// the client went away, there is nothing to report
+		return ctx.Err()
+	}
+
+	ffErr := &FFmpegError{Failure: FFmpegEncoderError, Err: err, Stderr: stderr.String()}
+
+	var exitErr *exec.ExitError
+	switch {
+	case runCtx.Err() != nil:
+		// either timeout, whichever comes first
+		ffErr.Failure = FFmpegTimeout
+	case errors.As(err, &exitErr):
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			ffErr.Failure = FFmpegKilled
+		} else if ffmpegMemoryMiB > 0 && outOfMemory(ffErr.Stderr) {
+			ffErr.Failure = FFmpegOutOfMemory
+		}
+	}
+
+	return ffErr
+}
+
+// videoAvailable is set by ProbeFFmpeg
+var videoAvailable bool
+
+// VideoAvailable reports whether the last call to ProbeFFmpeg found
+// a working ffmpeg and at least one usable video profile.
+func VideoAvailable() bool {
+	return videoAvailable
+}
+
+// FFmpegInfo describes the ffmpeg installation.
+type FFmpegInfo struct {
+	Version  string
+	Encoders map[string]bool
+}
+
+// ProbeFFmpeg checks the version of ffmpeg and the video encoders it
+// supports; profiles using missing encoders are disabled. It also looks
+// for the tools enforcing the configured limits and makes sure
+// the ffmpeg timeout expires before the render deadline.
+// It must be called before serving requests.
+func ProbeFFmpeg(ctx context.Context) (FFmpegInfo, error) {
+	info := FFmpegInfo{Encoders: map[string]bool{}}
+	videoAvailable = false
+
+	if renderTimeout > 0 && (ffmpegTimeout <= 0 || ffmpegTimeout >= renderTimeout) {
+		ffmpegTimeout = renderTimeout * 2 / 3
+		log.WarningLogger.Printf("ffmpeg timeout must be shorter than the render timeout, lowered to %v", ffmpegTimeout)
+	}
+
+	ffmpegWrappers = probeWrappers()
+
+	version, err := exec.CommandContext(ctx, ffmpegPath, "-hide_banner", "-version").Output()
+	if err != nil {
+		return info, err
+	}
+
+	// the first line looks like "ffmpeg version 6.1.1 Copyright ..."
+	if fields := strings.Fields(string(version)); len(fields) >= 3 {
+		info.Version = fields[2]
+	}
+
+	encoders, err := exec.CommandContext(ctx, ffmpegPath, "-hide_banner", "-encoders").Output()
+	if err != nil {
+		return info, err
+	}
+
+	// encoder lines look like " V....D libx264    libx264 H.264 ..."
+	scanner := bufio.NewScanner(bytes.NewReader(encoders))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && len(fields[0]) == 6 && fields[0][0] == 'V' {
+			info.Encoders[fields[1]] = true
+		}
+	}
+
+	var available []Profile
+	for _, profile := range profiles {
+		if encoder := profile.encoder(); encoder != "" && !info.Encoders[encoder] {
+			log.WarningLogger.Printf("video profile %s disabled, ffmpeg has no %s encoder", profile.Name, encoder)
+			continue
+		}
+		available = append(available, profile)
+	}
+
+	profiles = available
+	videoAvailable = len(profiles) > 0
+
+	return info, nil
+}
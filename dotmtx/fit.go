@@ -0,0 +1,174 @@
+package dotmtx
+
+import (
+	"math"
+	"strings"
+
+	"golang.org/x/image/font"
+)
+
+// Adjustment is a set of changes applied to an animation
+// to keep it within resource limits.
+type Adjustment int
+
+const (
+	// AdjustDots means the dots were drawn smaller than usual.
+	AdjustDots Adjustment = 1 << iota
+	// AdjustPadding means the padding between dots was removed.
+	AdjustPadding
+	// AdjustWidth means the display width multiplier was lowered.
+	AdjustWidth
+	// AdjustBlank means the blank space multiplier was lowered.
+	AdjustBlank
+)
+
+var adjustmentNames = [...]string{"smaller dots", "no padding", "narrower display", "less blank space"}
+
+func (a Adjustment) String() string {
+	var names []string
+	for i, name := range adjustmentNames {
+		if a&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// fallbackGeometries lists dot geometries to be tried in order
+// when an animation does not fit within resource limits.
+var fallbackGeometries = [...]Geometry{
+	DefaultGeometry,
+	{Inner: 4, Padding: 1},
+	{Inner: 3, Padding: 1},
+	{Inner: 2, Padding: 1},
+	{Inner: 3, Padding: 0},
+	{Inner: 2, Padding: 0},
+	{Inner: 1, Padding: 0},
+}
+
+// dots returns the dot geometry selected for opts.
+func (opts *Options) dots() Geometry {
+	if opts.geometry == (Geometry{}) {
+		return DefaultGeometry
+	}
+	return opts.geometry
+}
+
+// fitsWidth reports whether a display with the given number
+// of columns drawn with the given geometry is within MaxWidth.
+func fitsWidth(columns float64, dots Geometry) bool {
+	width := dots.Width(columns)
+	return !math.IsNaN(width) && !math.IsInf(width, 0) && width >= 0 && width <= MaxWidth
+}
+
+// fits reports whether the frames and the tape of the scrolling
+// animation drawn with the given geometry are within resource limits.
+func (tape tapeLayout) fits(dots Geometry) bool {
+	columns := tape.backingColumns()
+
+	// one frame per window position
+	frames := 1.0
+	if !tape.static {
+		frames = math.Max(1, tape.steps())
+	}
+	pixels := frames * dots.Width(tape.windowColumns) * dots.Width(float64(tape.rows))
+
+	return fitsWidth(tape.windowColumns, dots) && columns >= 0 && columns <= MaxTapeColumns && pixels <= MaxPixels
+}
+
+// WidthLimit returns the largest display width multiplier that makes
+// a difference for opts: looping text is never shown in a display wider
+// than itself followed by the blank space, and panels have a fixed size.
+// Bouncing text is only bounded by resource limits.
+func WidthLimit(opts Options) float64 {
+	switch {
+	case opts.PanelColumns > 0 && opts.PanelRows > 0:
+		return opts.Width
+	case opts.Bounce && opts.Speed != 0:
+		return math.Inf(1)
+	default:
+		return 1 + opts.Blank
+	}
+}
+
+// fits reports whether the animation for opts drawn
+// with the given geometry is within resource limits.
+func fits(opts Options, dots Geometry) bool {
+	if opts.PanelColumns > 0 && opts.PanelRows > 0 && opts.Speed == 0 {
+		return fitsWidth(float64(opts.PanelColumns), dots)
+	}
+
+	textColumns := font.MeasureString(Font, opts.Text).Ceil()
+	return measureTape(opts, textColumns).fits(dots)
+}
+
+// fit looks for the closest variant of opts that fits within resource
+// limits, trying smaller dots first, then a narrower display and finally
+// less blank space. It reports the adjustments made and whether
+// a fitting variant was found.
+func fit(opts Options) (Options, Adjustment, bool) {
+	adjust := func(dots Geometry) Adjustment {
+		var adj Adjustment
+		if dots.Inner < DotInnerSize {
+			adj |= AdjustDots
+		}
+		if dots.Padding < DotPadding {
+			adj |= AdjustPadding
+		}
+		return adj
+	}
+
+	for _, dots := range fallbackGeometries {
+		if fits(opts, dots) {
+			opts.geometry = dots
+			return opts, adjust(dots), true
+		}
+	}
+
+	smallest := fallbackGeometries[len(fallbackGeometries)-1]
+	opts.geometry = smallest
+
+	var adj Adjustment
+
+	if opts.Width > 1 {
+		opts.Width = 1
+		adj |= AdjustWidth
+
+		if fits(opts, smallest) {
+			return opts, adjust(smallest) | adj, true
+		}
+	}
+
+	// find the largest blank space multiplier that fits, if any
+	if opts.Blank > 0 {
+		noBlank := opts
+		noBlank.Blank = 0
+		if !fits(noBlank, smallest) {
+			return opts, adj, false
+		}
+
+		low, high := 0.0, opts.Blank
+
+		for range 32 {
+			mid := (low + high) / 2
+			opts.Blank = mid
+			if fits(opts, smallest) {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+
+		opts.Blank = low
+		return opts, adjust(smallest) | adj | AdjustBlank, true
+	}
+
+	return opts, adj, false
+}
+
+// Fit reports the adjustments that will be applied to keep the animation
+// described by opts within resource limits, and whether it can fit at all.
+func Fit(opts Options) (Adjustment, bool) {
+	_, adj, ok := fit(opts)
+	return adj, ok
+}
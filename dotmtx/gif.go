@@ -1,37 +1,17 @@
 package dotmtx
 
 import (
-	"bytes"
-	_ "embed"
+	"context"
 	"errors"
 	"image"
 	"image/color"
-	"image/draw"
 	"image/gif"
 	"math"
 
 	"golang.org/x/image/font"
-	"golang.org/x/image/math/fixed"
-
-	"petbots.fbbdev.it/dotmtxbot/log"
 )
 
-//go:embed toobig.gif
-var tooBigGifData []byte
-var tooBigGif *gif.GIF
-
-func init() {
-	img, err := gif.DecodeAll(bytes.NewReader(tooBigGifData))
-	if err != nil {
-		log.ErrorLogger.Print("gif: ", err)
-		log.FatalLogger.Fatal("could not load error message gif")
-	}
-
-	tooBigGif = img
-}
-
 var errWidthOverflow = errors.New("maximum width exceeded")
-var errUnexpectedSubImageFormat = errors.New("unexpected sub-image format")
 
 var palette = [...]color.Color{
 	color.Black,
@@ -47,9 +27,11 @@ func max(x, y int) int {
 	}
 }
 
-func drawDotMatrix(text string) (img *image.Paletted, err error) {
+// drawDotMatrix draws text on a single line, one pixel per dot,
+// decorating the characters covered by the given style spans.
+func drawDotMatrix(text string, spans []StyleSpan) (img *image.Paletted, err error) {
 	advance := font.MeasureString(Font, text)
-	if advance.Ceil() > MaxWidth/DotSize {
+	if advance.Ceil() > MaxWidth {
 		return nil, errWidthOverflow
 	}
 
@@ -58,225 +40,160 @@ func drawDotMatrix(text string) (img *image.Paletted, err error) {
 		palette[:],
 	)
 
-	draw.Draw(img, img.Bounds(), image.NewUniform(palette[1]), image.Point{}, draw.Src)
-
-	drawer := font.Drawer{
-		Dst:  img,
-		Src:  image.NewUniform(palette[2]),
-		Face: Font,
-		Dot:  fixed.Point26_6{X: fixed.I(0), Y: Font.Metrics().Ascent},
+	for i := range img.Pix {
+		img.Pix[i] = 1
 	}
 
-	drawer.DrawString(text)
+	bounds := drawText(img, 0, Font.Metrics().Ascent.Ceil(), text)
+	if len(spans) > 0 {
+		applyStyles(img, bounds, spans)
+	}
 
 	return
 }
 
-func MakeGif(speed float64, width float64, blank float64, text string) (*gif.GIF, error) {
-	width = math.Min(width, 1+blank)
-	if speed == 0 {
-		blank = math.Max(0, width-1)
-	}
-
-	dotMatrix, err := drawDotMatrix(text)
-	if err != nil {
-		if err == errWidthOverflow {
-			return tooBigGif, nil
-		}
+// drawDots expands the dot matrix src into dst, which must be blank,
+// drawing each dot as a square with the given geometry; sourceColumn maps
+// dst columns to src columns and returns -1 for blank columns.
+func drawDots(dst *image.Paletted, src *image.Paletted, dots Geometry, sourceColumn func(x int) int) {
+	size, padding := dots.Size(), dots.Padding
+	tiles := dots.tiles()
 
-		return nil, err
-	}
+	columns := (dst.Rect.Dx() - 2*padding) / size
+	rows := min(src.Rect.Dy(), (dst.Rect.Dy()-2*padding)/size)
 
-	// log.InfoLogger.Print("text rendering done")
+	for y := 0; y < rows; y++ {
+		top := (2*padding+y*size)*dst.Stride + 2*padding
+		row := dst.Pix[top : top+columns*size]
 
-	dotMatrixWidth := dotMatrix.Rect.Dx()
-	dotMatrixHeight := dotMatrix.Rect.Dy()
-
-	windowColumns := math.Round(width * float64(dotMatrixWidth))
-	windowWidth := windowColumns*DotSize + 2*DotPadding
+		for x := 0; x < columns; x++ {
+			dotState := uint8(1)
+			if sx := sourceColumn(x); sx >= 0 {
+				dotState = src.Pix[y*src.Stride+sx]
+			}
 
-	backingImageColumns := math.Round((1 + blank) * float64(dotMatrixWidth))
-	backingImageWidth := (backingImageColumns+windowColumns)*DotSize + 2*DotPadding
-	backingImageHeight := dotMatrixHeight*DotSize + 2*DotPadding
+			copy(row[x*size:], tiles[dotState])
+		}
 
-	// handle overflows; no need to check windowWidth as width <= 1+blank
-	if math.IsNaN(backingImageWidth) || math.IsInf(backingImageWidth, 0) || backingImageWidth < 0 || backingImageWidth > MaxWidth {
-		return tooBigGif, nil
+		for dy := 1; dy < dots.Inner; dy++ {
+			copy(dst.Pix[top+dy*dst.Stride:], row)
+		}
 	}
+}
 
-	// log.InfoLogger.Print("size is valid", dotMatrixWidth, dotMatrixHeight, windowColumns, windowWidth, backingImageColumns, backingImageWidth)
-
-	// compute frame count and delay
-	frameCount := int(backingImageColumns)
-
-	reverse := speed < 0
-	if reverse {
-		speed = -speed
+// gifLoopCount converts a number of plays to a GIF loop count.
+func gifLoopCount(loops int) int {
+	switch {
+	case loops == 1:
+		return -1
+	case loops > 1:
+		return loops - 1
+	default:
+		return 0
 	}
+}
 
-	delay := 100 / (speed * float64(CharWidthInDots))
-	if speed == 0 {
-		delay = 0
+// MakeGif renders the animation described by opts, drawing all frames
+// at once. It gives up with the context error as soon as ctx is cancelled.
+func MakeGif(ctx context.Context, opts Options) (*gif.GIF, error) {
+	anim, err := MakeAnimation(ctx, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	// log.InfoLogger.Print("timing:", frameCount, reverse, delay)
+	return anim.GIF(ctx)
+}
 
-	// handle overflows
-	if math.IsNaN(delay) || math.IsInf(delay, 0) || delay < 0 || delay > math.MaxUint16 {
-		delay = 0
+// MakeAnimation lays out the animation described by opts;
+// frames are drawn on demand. It gives up with the context error
+// as soon as ctx is cancelled.
+func MakeAnimation(ctx context.Context, opts Options) (*Animation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	// log.InfoLogger.Print("timing is valid")
-
-	if delay > 0 {
-		// delay must be at least 2 otherwise some players won't work
-		delay = math.Max(2, math.Round(delay))
+	opts, _, ok := fit(opts)
+	if !ok {
+		return MakeAnimation(ctx, ErrorOptions(errTooBig, opts))
 	}
 
-	backingImage := image.NewPaletted(
-		image.Rect(0, 0, int(backingImageWidth), backingImageHeight),
-		palette[:],
-	)
-
-	dmtxStride := dotMatrix.Stride
-	bimgStride := backingImage.Stride
-
-	// draw dots
-	for y := 0; y < dotMatrixHeight; y++ {
-		for x := 0; x < dotMatrixWidth; x++ {
-			dotState := dotMatrix.Pix[y*dmtxStride+x]
-
-			for dy := 0; dy < DotInnerSize; dy++ {
-				for dx := 0; dx < DotInnerSize; dx++ {
-					backingImage.Pix[(2*DotPadding+y*DotSize+dy)*bimgStride+(2*DotPadding+x*DotSize+dx)] = dotState
-					if x < int(windowColumns) {
-						backingImage.Pix[(2*DotPadding+y*DotSize+dy)*bimgStride+(2*DotPadding+(x+int(backingImageColumns))*DotSize+dx)] = dotState
-					}
-				}
-			}
-		}
+	if opts.PanelColumns > 0 && opts.PanelRows > 0 && opts.Speed == 0 {
+		return makePanelPages(ctx, opts)
 	}
 
-	// log.InfoLogger.Print("text written to backing image")
-
-	dotMatrix = nil
-
-	// draw blank dots
-	for y := 0; y < dotMatrixHeight; y++ {
-		for x := dotMatrixWidth; x < int(backingImageColumns); x++ {
-			for dy := 0; dy < DotInnerSize; dy++ {
-				for dx := 0; dx < DotInnerSize; dx++ {
-					backingImage.Pix[(2*DotPadding+y*DotSize+dy)*bimgStride+(2*DotPadding+x*DotSize+dx)] = 1
-					if x < int(windowColumns) {
-						backingImage.Pix[(2*DotPadding+y*DotSize+dy)*bimgStride+(2*DotPadding+(x+int(backingImageColumns))*DotSize+dx)] = 1
-					}
-				}
-			}
+	s, err := makeScroll(opts)
+	if err != nil {
+		if err == errWidthOverflow {
+			return MakeAnimation(ctx, ErrorOptions(errTooBig, opts))
 		}
-	}
 
-	// log.InfoLogger.Print("blank written to backing image")
+		return nil, err
+	}
 
-	if delay == 0 {
-		subImage := backingImage.SubImage(image.Rect(0, 0, int(windowWidth), backingImageHeight))
-		palettedSubImage, ok := subImage.(*image.Paletted)
-		if !ok {
-			return nil, errUnexpectedSubImageFormat
-		}
-		return &gif.GIF{
-			Image:     []*image.Paletted{palettedSubImage},
-			Delay:     []int{0},
-			LoopCount: 0,
-			Disposal:  []byte{0},
-			Config: image.Config{
-				ColorModel: color.Palette(palette[:]),
-				Width:      palettedSubImage.Rect.Dx(),
-				Height:     palettedSubImage.Rect.Dy(),
-			},
-			BackgroundIndex: 0,
+	if s.static() {
+		x := s.columns[0] * s.dots.Size()
+		return &Animation{
+			Width:   s.width,
+			Height:  s.height,
+			Palette: s.palette,
+			Delay:   []int{0},
+			draw:    func(frame *image.Paletted, _ int) { s.drawWindow(frame, x) },
 		}, nil
 	}
 
-	// determine starting point of animation and blank range
-	var column, step, blankRangeStart, blankRangeEnd int
+	// GIF delays are whole centiseconds: each frame lasts until the running
+	// total of the exact delays, rounded, so that the total duration of the
+	// animation is preserved. Positions that would be shown for less than
+	// 2cs, which some players do not honor, are skipped; blank frames
+	// are compressed into one.
+	frameColumns := make([]int, 0, len(s.columns))
+	frameDelays := make([]int, 0, len(s.columns))
+
+	var elapsed float64
+	var elapsedRounded int
+
+	for i, column := range s.columns {
+		elapsed += s.pauses[i] + s.moves[i]
+		frameDelay := int(math.Round(elapsed)) - elapsedRounded
+
+		if frameDelay < 2 {
+			n := len(frameDelays)
+			switch {
+			case i < len(s.columns)-1:
+				continue
+			case n > 0:
+				// the previous frame lasts until the end of the loop
+				frameDelays[n-1] += frameDelay
+				continue
+			}
 
-	if reverse {
-		column = int(math.Min(backingImageColumns, float64(dotMatrixWidth)+windowColumns) - windowColumns)
-		step = -1
-		blankRangeStart = column + 1
-		blankRangeEnd = int(backingImageColumns-windowColumns) + 1
-	} else {
-		column = max(dotMatrixWidth, int(backingImageColumns-windowColumns))
-		step = 1
-		blankRangeStart = dotMatrixWidth
-		blankRangeEnd = column
-	}
+			frameDelay = 2
+		}
 
-	blankCount := blankRangeEnd - blankRangeStart
-	if blankCount < 0 {
-		blankCount = 0
-	}
+		elapsedRounded += frameDelay
 
-	// compress blank frames into one
-	if blankCount > 0 {
-		frameCount -= (blankCount - 1)
-	}
+		if n := len(frameColumns); n > 0 && s.isBlank(column) && s.isBlank(frameColumns[n-1]) {
+			frameDelays[n-1] += frameDelay
+			continue
+		}
 
-	// compute delay of last (possibly blank) frame
-	lastDelay := int(delay)
-	if blankCount > 0 {
-		lastDelay *= blankCount
+		frameColumns = append(frameColumns, column)
+		frameDelays = append(frameDelays, frameDelay)
 	}
 
 	// log.InfoLogger.Print(
-	// 	"column:", column,
-	// 	"step:", step,
-	// 	"blankRangeStart:", blankRangeStart,
-	// 	"blankRangeEnd:", blankRangeEnd,
-	// 	"blankCount:", blankCount,
-	// 	"frameCount:", frameCount,
-	//  "delay:", delay,
-	// 	"lastDelay:", lastDelay,
+	// 	"frameColumns:", frameColumns,
+	// 	"frameDelays:", frameDelays,
 	// )
 
-	anim := gif.GIF{
-		Image:     make([]*image.Paletted, frameCount),
-		Delay:     make([]int, frameCount),
-		LoopCount: 0,
-		Disposal:  make([]byte, frameCount),
-		Config: image.Config{
-			ColorModel: color.Palette(palette[:]),
-			Width:      int(windowWidth),
-			Height:     backingImageHeight,
+	return &Animation{
+		Width:     s.width,
+		Height:    s.height,
+		Palette:   s.palette,
+		Delay:     frameDelays,
+		LoopCount: gifLoopCount(opts.Loops),
+		draw: func(frame *image.Paletted, i int) {
+			s.drawWindow(frame, frameColumns[i]*s.dots.Size())
 		},
-		BackgroundIndex: 0,
-	}
-
-	for i := range anim.Image {
-		x := column * DotSize
-
-		subImage, ok := backingImage.SubImage(image.Rect(x, 0, x+int(windowWidth), backingImageHeight)).(*image.Paletted)
-		if !ok {
-			return nil, errUnexpectedSubImageFormat
-		}
-
-		subImage.Rect = image.Rect(0, 0, int(windowWidth), backingImageHeight)
-
-		anim.Image[i] = subImage
-		anim.Delay[i] = int(delay)
-		anim.Disposal[i] = 0
-
-		column += step
-		if column >= int(backingImageColumns) {
-			column -= int(backingImageColumns)
-		} else if column < 0 {
-			column += int(backingImageColumns)
-		}
-	}
-
-	anim.Delay[frameCount-1] = lastDelay
-
-	// log.InfoLogger.Print("gif generation complete")
-
-	return &anim, nil
+	}, nil
 }
@@ -1,10 +1,10 @@
 package dotmtx
 
 import (
-	"image/gif"
+	"context"
+	"errors"
 	"net/http"
 	"os"
-	"strconv"
 
 	"petbots.fbbdev.it/dotmtxbot/log"
 )
@@ -19,51 +19,86 @@ func init() {
 }
 
 func GifHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !allowRead(w, r) {
 		return
 	}
 
-	speed, err := strconv.ParseFloat(r.URL.Query().Get("speed"), 64)
+	status := http.StatusOK
+
+	// invalid parameters get an animation explaining what is wrong
+	opts, err := ParseOptions(r.URL.Query())
 	if err != nil {
-		http.NotFound(w, r)
-		return
+		opts, status = ErrorOptions(err, opts), http.StatusBadRequest
 	}
 
-	width, err := strconv.ParseFloat(r.URL.Query().Get("width"), 64)
-	if err != nil || width <= 0 {
-		http.NotFound(w, r)
+	if checkCache(w, r, "gif", opts, status) {
 		return
 	}
 
-	blank, err := strconv.ParseFloat(r.URL.Query().Get("blank"), 64)
-	if err != nil || blank < 0 {
-		http.NotFound(w, r)
+	// log.InfoLogger.Print("parameters are valid")
+
+	ctx, cancel := renderContext(r)
+	defer cancel()
+
+	serveGif(ctx, w, r, opts, status)
+}
+
+// serveGif renders the animation for opts as a GIF and streams it
+// as the response with the given status, so that the whole file
+// is never held in memory. Range requests are not supported.
+func serveGif(ctx context.Context, w http.ResponseWriter, r *http.Request, opts Options, status int) {
+	anim, err := MakeAnimation(ctx, opts)
+	if renderCancelled(w, r, err) {
+		return
+	} else if err != nil {
+		log.ErrorLogger.Print("MakeAnimation: ", err)
+		log.WarningLogger.Print("gif generation failed")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	if len(r.URL.Query().Get("text")) > MaxChars {
-		http.NotFound(w, r)
+	w.Header().Set("Content-Type", "image/gif")
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
 		return
 	}
 
-	// log.InfoLogger.Print("parameters are valid")
+	body := &bodyWriter{w: w, status: status}
+	if err := anim.EncodeGif(ctx, body); err != nil && !body.started {
+		if renderCancelled(w, r, err) {
+			return
+		}
 
-	anim, err := MakeGif(speed, width, blank, r.URL.Query().Get("text"))
-	if err != nil {
-		log.ErrorLogger.Print("MakeGif: ", err)
-		log.WarningLogger.Print("gif generation failed")
+		log.ErrorLogger.Print("gif: ", err)
+		log.WarningLogger.Print("could not encode gif")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+	} else if errors.Is(err, context.Canceled) {
+		// the client went away, nobody will read the rest
+		log.InfoLogger.Printf("render cancelled by client (url=%v)", r.URL)
+	} else if err != nil {
+		// part of the file has been sent already: abort the response
+		// so that neither clients nor caches take it for complete
+		log.ErrorLogger.Print("gif: ", err)
+		log.WarningLogger.Printf("gif stream interrupted (url=%v)", r.URL)
+		panic(http.ErrAbortHandler)
 	}
+}
 
-	w.Header().Add("Content-Type", "image/gif")
-	err = gif.EncodeAll(w, anim)
-	if err != nil {
-		log.ErrorLogger.Print("gif/http: ", err)
-		log.WarningLogger.Print("could not encode gif or write http response")
-		// just in case nothing was written
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+// bodyWriter writes the response header with the given status
+// right before the first byte of the body, so that errors occurring
+// before anything is written can still be reported with their own status.
+type bodyWriter struct {
+	w       http.ResponseWriter
+	status  int
+	started bool
+}
+
+func (bw *bodyWriter) Write(p []byte) (int, error) {
+	if !bw.started {
+		bw.w.WriteHeader(bw.status)
+		bw.started = true
 	}
+
+	return bw.w.Write(p)
 }
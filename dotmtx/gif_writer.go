@@ -0,0 +1,170 @@
+package dotmtx
+
+import (
+	"bufio"
+	"bytes"
+	"compress/lzw"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"math/bits"
+)
+
+var errGifPalette = errors.New("gif: palette must have between 1 and 256 colors")
+var errGifFrameSize = errors.New("gif: frame size does not match the animation")
+
+// gifWriter encodes an animation frame by frame. All frames share the global
+// color table and cover the whole logical screen; within these constraints
+// the output is the same as that of gif.EncodeAll.
+type gifWriter struct {
+	w *bufio.Writer
+
+	width    int
+	height   int
+	litWidth int
+
+	buf [256]byte
+}
+
+// newGifWriter writes the header of the animation to w.
+func newGifWriter(w io.Writer, a *Animation) (*gifWriter, error) {
+	if len(a.Palette) == 0 || len(a.Palette) > 256 {
+		return nil, errGifPalette
+	}
+
+	// the color table has 2^(tableBits+1) entries
+	tableBits := 0
+	if len(a.Palette) > 2 {
+		tableBits = bits.Len(uint(len(a.Palette)-1)) - 1
+	}
+
+	gw := &gifWriter{
+		w:        bufio.NewWriter(w),
+		width:    a.Width,
+		height:   a.Height,
+		litWidth: max(2, tableBits+1),
+	}
+
+	gw.w.WriteString("GIF89a")
+
+	// logical screen descriptor
+	binary.LittleEndian.PutUint16(gw.buf[0:], uint16(a.Width))
+	binary.LittleEndian.PutUint16(gw.buf[2:], uint16(a.Height))
+	gw.buf[4] = 0x80 | byte(tableBits) // global color table
+	gw.buf[5] = 0                      // background color index
+	gw.buf[6] = 0                      // pixel aspect ratio
+	gw.w.Write(gw.buf[:7])
+
+	table := make([]byte, 3<<(tableBits+1))
+	for i, c := range a.Palette {
+		r, g, b, _ := c.RGBA()
+		table[3*i], table[3*i+1], table[3*i+2] = byte(r>>8), byte(g>>8), byte(b>>8)
+	}
+	gw.w.Write(table)
+
+	if a.Len() > 1 && a.LoopCount >= 0 {
+		gw.w.Write([]byte{0x21, 0xff, 0x0b})
+		gw.w.WriteString("NETSCAPE2.0")
+		gw.w.Write([]byte{0x03, 0x01, byte(a.LoopCount), byte(a.LoopCount >> 8), 0x00})
+	}
+
+	return gw, nil
+}
+
+// encodeFrame returns the encoded frame, which must use the global palette;
+// frames are independent of each other and may be encoded concurrently.
+func (gw *gifWriter) encodeFrame(frame *image.Paletted, delay int) ([]byte, error) {
+	if frame.Rect != image.Rect(0, 0, gw.width, gw.height) {
+		return nil, errGifFrameSize
+	}
+
+	var buf bytes.Buffer
+	var header [11]byte
+
+	// graphic control extension
+	if delay > 0 {
+		header[0], header[1], header[2], header[3] = 0x21, 0xf9, 0x04, 0x00
+		binary.LittleEndian.PutUint16(header[4:], uint16(delay))
+		header[6], header[7] = 0x00, 0x00
+		buf.Write(header[:8])
+	}
+
+	// image descriptor, without local color table
+	header[0] = 0x2c
+	binary.LittleEndian.PutUint16(header[1:], 0)
+	binary.LittleEndian.PutUint16(header[3:], 0)
+	binary.LittleEndian.PutUint16(header[5:], uint16(gw.width))
+	binary.LittleEndian.PutUint16(header[7:], uint16(gw.height))
+	header[9] = 0x00
+	header[10] = byte(gw.litWidth)
+	buf.Write(header[:11])
+
+	blocks := &blockWriter{w: &buf}
+	compressor := lzw.NewWriter(blocks, lzw.LSB, gw.litWidth)
+
+	for y := 0; y < gw.height; y++ {
+		compressor.Write(frame.Pix[y*frame.Stride : y*frame.Stride+gw.width])
+	}
+
+	// writes to a bytes.Buffer cannot fail
+	compressor.Close()
+	blocks.close()
+
+	return buf.Bytes(), nil
+}
+
+// writeFrame writes a frame returned by encodeFrame.
+func (gw *gifWriter) writeFrame(data []byte) error {
+	_, err := gw.w.Write(data)
+	return err
+}
+
+// close writes the trailer and flushes buffered data.
+func (gw *gifWriter) close() error {
+	gw.w.WriteByte(0x3b)
+	return gw.w.Flush()
+}
+
+// blockWriter splits data into GIF sub-blocks of up to 255 bytes.
+type blockWriter struct {
+	w   *bytes.Buffer
+	n   int
+	buf [256]byte
+}
+
+func (bw *blockWriter) Write(p []byte) (int, error) {
+	for written := 0; written < len(p); {
+		n := copy(bw.buf[1+bw.n:], p[written:])
+		bw.n += n
+		written += n
+
+		if bw.n == 255 {
+			if err := bw.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+func (bw *blockWriter) flush() error {
+	if bw.n == 0 {
+		return nil
+	}
+
+	bw.buf[0] = byte(bw.n)
+	_, err := bw.w.Write(bw.buf[:1+bw.n])
+	bw.n = 0
+	return err
+}
+
+// close writes the last sub-block and the block terminator.
+func (bw *blockWriter) close() error {
+	if err := bw.flush(); err != nil {
+		return err
+	}
+
+	return bw.w.WriteByte(0)
+}
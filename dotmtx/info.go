@@ -0,0 +1,69 @@
+package dotmtx
+
+import (
+	"context"
+	"time"
+)
+
+// Info describes an animation as delivered in a given format.
+type Info struct {
+	Width  int
+	Height int
+	// Duration is the total playing time or, for animations
+	// that loop forever, the duration of one loop.
+	Duration time.Duration
+}
+
+// Describe returns the size and duration of the animation described
+// by opts as delivered in opts.Format, without drawing its frames.
+// Videos are assumed to be encoded with the default profile
+// for the format. It gives up with the context error between
+// layout steps when ctx is cancelled.
+func Describe(ctx context.Context, opts Options) (Info, error) {
+	var info Info
+
+	var video *Video
+	if opts.Format.Video() {
+		var err error
+		if video, err = MakeVideo(opts); err != nil {
+			return info, err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return info, err
+		}
+	}
+
+	if video != nil {
+		info.Width, info.Height = video.Width, video.Height
+		info.Duration = time.Duration(video.FrameCount) * time.Second / time.Duration(video.FPS)
+	} else {
+		anim, err := MakeAnimation(ctx, opts)
+		if err != nil {
+			return info, err
+		}
+
+		var delay int
+		for _, d := range anim.Delay {
+			delay += d
+		}
+
+		info.Width, info.Height = anim.Width, anim.Height
+		info.Duration = time.Duration(delay) * 10 * time.Millisecond
+	}
+
+	info.Duration *= time.Duration(max(1, opts.Loops))
+
+	if opts.Format.Video() {
+		if profile, ok := selectProfile("", "."+opts.Format.String()); ok && profile.Scale > 1 {
+			info.Width *= profile.Scale
+			info.Height *= profile.Scale
+		}
+
+		// videos are padded to even dimensions
+		info.Width += info.Width % 2
+		info.Height += info.Height % 2
+	}
+
+	return info, nil
+}
@@ -0,0 +1,350 @@
+package dotmtx
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"petbots.fbbdev.it/dotmtxbot/i18n"
+)
+
+// Easing selects how the scrolling speed varies along each pass.
+type Easing int
+
+const (
+	EaseNone Easing = iota
+	EaseIn
+	EaseOut
+	EaseInOut
+)
+
+var easingNames = [...]string{"none", "in", "out", "inout"}
+
+func (e Easing) String() string {
+	if e < 0 || int(e) >= len(easingNames) {
+		return fmt.Sprintf("Easing(%d)", int(e))
+	}
+	return easingNames[e]
+}
+
+// Format selects the file format an animation is delivered in.
+type Format int
+
+const (
+	FormatMP4 Format = iota
+	FormatGIF
+	FormatWebM
+)
+
+var formatNames = [...]string{"mp4", "gif", "webm"}
+
+func (f Format) String() string {
+	if f < 0 || int(f) >= len(formatNames) {
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+	return formatNames[f]
+}
+
+// Video reports whether the format is converted by ffmpeg.
+func (f Format) Video() bool {
+	return f != FormatGIF
+}
+
+// Resource limits for animation options
+const (
+	MaxDwell = 30
+	MaxLoops = 100
+)
+
+// Options collects all parameters of a dot matrix animation.
+type Options struct {
+	// Speed is the number of characters scrolling out of the display
+	// in one second; negative values reverse the scrolling direction.
+	Speed float64
+	// Width is the display width multiplier relative to the text width.
+	Width float64
+	// Blank is the blank space multiplier relative to the text width.
+	Blank float64
+	Text  string
+
+	// Dwell is the pause in seconds when the text is fully visible
+	// or, when bouncing, at both ends of the run.
+	Dwell float64
+	// Bounce scrolls the text back and forth instead of looping it.
+	Bounce bool
+	Easing Easing
+	// Loops is the number of times the animation plays; 0 means forever.
+	Loops int
+	// Phase is the initial scroll offset as a fraction of one loop.
+	Phase float64
+
+	// PanelColumns and PanelRows set a fixed display size in dots;
+	// when zero, the display size follows the text.
+	PanelColumns int
+	PanelRows    int
+	// Align is the alignment of static text on a panel.
+	Align Align
+	// Overflow selects how static text that does not fit a panel is shown.
+	Overflow Overflow
+
+	// FPS is the constant frame rate of video output; when zero, videos
+	// keep the timing of the GIF animation.
+	FPS int
+	// Smooth lets videos scroll by pixels instead of whole dots.
+	Smooth bool
+
+	// Color is the color of lit dots.
+	Color Color
+
+	// Format is the file format requested for the animation;
+	// it does not change the frames.
+	Format Format
+
+	// Lang is the language of the reason shown by error animations;
+	// it does not change other animations.
+	Lang string
+
+	// Styles decorate parts of the text; they are not applied
+	// to static text wrapped on a panel.
+	Styles []StyleSpan
+
+	// geometry is the dot geometry selected by fit
+	geometry Geometry
+}
+
+// Set parses value and assigns it to the optional parameter name.
+// It returns an *OptionError wrapping ErrUnknownOption or ErrInvalidOption
+// when name or value are not valid.
+func (opts *Options) Set(name string, value string) error {
+	var err error
+
+	switch name {
+	case "dwell":
+		opts.Dwell, err = strconv.ParseFloat(value, 64)
+		if err == nil && !(opts.Dwell >= 0 && opts.Dwell <= MaxDwell) {
+			err = ErrInvalidOption
+		}
+	case "bounce":
+		opts.Bounce, err = strconv.ParseBool(value)
+	case "ease":
+		err = ErrInvalidOption
+		for i, n := range easingNames {
+			if value == n {
+				opts.Easing, err = Easing(i), nil
+				break
+			}
+		}
+	case "loops":
+		opts.Loops, err = strconv.Atoi(value)
+		if err == nil && (opts.Loops < 0 || opts.Loops > MaxLoops) {
+			err = ErrInvalidOption
+		}
+	case "phase":
+		opts.Phase, err = strconv.ParseFloat(value, 64)
+		if err == nil && !(opts.Phase >= 0 && opts.Phase < 1) {
+			err = ErrInvalidOption
+		}
+	case "panel":
+		columns, rows, ok := strings.Cut(value, "x")
+		if !ok {
+			err = ErrInvalidOption
+			break
+		}
+		if opts.PanelColumns, err = strconv.Atoi(columns); err != nil {
+			break
+		}
+		if opts.PanelRows, err = strconv.Atoi(rows); err != nil {
+			break
+		}
+		// compare without multiplying, which could overflow
+		if opts.PanelColumns < 1 || opts.PanelColumns > (MaxWidth-2*DotPadding)/DotSize ||
+			opts.PanelRows < 1 || opts.PanelRows > MaxPanelRows {
+			err = ErrInvalidOption
+		}
+	case "align":
+		err = ErrInvalidOption
+		for i, n := range alignNames {
+			if value == n {
+				opts.Align, err = Align(i), nil
+				break
+			}
+		}
+	case "overflow":
+		err = ErrInvalidOption
+		for i, n := range overflowNames {
+			if value == n {
+				opts.Overflow, err = Overflow(i), nil
+				break
+			}
+		}
+	case "fps":
+		opts.FPS, err = strconv.Atoi(value)
+		if err == nil && (opts.FPS < 0 || opts.FPS > MaxFPS) {
+			err = ErrInvalidOption
+		}
+	case "smooth":
+		opts.Smooth, err = strconv.ParseBool(value)
+	case "color":
+		err = ErrInvalidOption
+		for i, n := range colorNames {
+			if value == n {
+				opts.Color, err = Color(i), nil
+				break
+			}
+		}
+	case "style":
+		opts.Styles, err = parseStyles(value)
+	case "lang":
+		err = ErrInvalidOption
+		if i18n.Lang(value) == value {
+			opts.Lang, err = value, nil
+		}
+	case "format":
+		err = ErrInvalidOption
+		for i, n := range formatNames {
+			if value == n {
+				opts.Format, err = Format(i), nil
+				break
+			}
+		}
+	default:
+		return &OptionError{name, ErrUnknownOption}
+	}
+
+	if err != nil {
+		return &OptionError{name, ErrInvalidOption}
+	}
+
+	return nil
+}
+
+// TextFilter, when set, is called by Validate to decide
+// whether a text may be rendered.
+var TextFilter func(text string) bool
+
+// Validate checks the mandatory parameters and the text.
+func (opts *Options) Validate() error {
+	if math.IsNaN(opts.Speed) || math.IsInf(opts.Speed, 0) {
+		return ErrInvalidSpeed
+	}
+
+	if !(opts.Width > 0) || math.IsInf(opts.Width, 0) {
+		return ErrInvalidWidth
+	}
+
+	if !(opts.Blank >= 0) || math.IsInf(opts.Blank, 0) {
+		return ErrInvalidBlank
+	}
+
+	if len(opts.Text) > MaxChars {
+		return ErrTextTooLong
+	}
+
+	for _, r := range opts.Text {
+		if !SupportedChar(r) {
+			return &UnsupportedCharError{r}
+		}
+	}
+
+	if TextFilter != nil && !TextFilter(opts.Text) {
+		return ErrTextRefused
+	}
+
+	runes := utf8.RuneCountInString(opts.Text)
+	for _, span := range opts.Styles {
+		if span.End > runes {
+			return &OptionError{"style", ErrInvalidOption}
+		}
+	}
+
+	return nil
+}
+
+var optionalParams = [...]string{"dwell", "bounce", "ease", "loops", "phase", "panel", "align", "overflow", "fps", "smooth", "color", "style", "format", "lang"}
+
+// ParseOptions reads and validates animation parameters
+// from the query string of a render request. When they are not
+// valid, the color and language are still set if they are.
+func ParseOptions(query url.Values) (opts Options, err error) {
+	opts.Text = query.Get("text")
+
+	// they apply to error animations too, errors are reported below
+	for _, name := range [...]string{"color", "lang"} {
+		if query.Has(name) {
+			opts.Set(name, query.Get(name))
+		}
+	}
+
+	for _, name := range optionalParams {
+		if query.Has(name) {
+			if err = opts.Set(name, query.Get(name)); err != nil {
+				return
+			}
+		}
+	}
+
+	if err = opts.SetParams(query.Get("speed"), query.Get("width"), query.Get("blank")); err != nil {
+		return
+	}
+
+	err = opts.Validate()
+	return
+}
+
+// Values encodes opts as query parameters, omitting optional parameters
+// that have their default value.
+func (opts *Options) Values() url.Values {
+	params := url.Values{}
+	params.Set("speed", fmt.Sprint(opts.Speed))
+	params.Set("width", fmt.Sprint(opts.Width))
+	params.Set("blank", fmt.Sprint(opts.Blank))
+	params.Set("text", opts.Text)
+
+	if opts.Dwell != 0 {
+		params.Set("dwell", fmt.Sprint(opts.Dwell))
+	}
+	if opts.Bounce {
+		params.Set("bounce", "true")
+	}
+	if opts.Easing != EaseNone {
+		params.Set("ease", opts.Easing.String())
+	}
+	if opts.Loops != 0 {
+		params.Set("loops", fmt.Sprint(opts.Loops))
+	}
+	if opts.Phase != 0 {
+		params.Set("phase", fmt.Sprint(opts.Phase))
+	}
+	if opts.PanelColumns != 0 || opts.PanelRows != 0 {
+		params.Set("panel", fmt.Sprintf("%dx%d", opts.PanelColumns, opts.PanelRows))
+	}
+	if opts.Align != AlignLeft {
+		params.Set("align", opts.Align.String())
+	}
+	if opts.Overflow != OverflowPage {
+		params.Set("overflow", opts.Overflow.String())
+	}
+	if opts.FPS != 0 {
+		params.Set("fps", fmt.Sprint(opts.FPS))
+	}
+	if opts.Smooth {
+		params.Set("smooth", "true")
+	}
+	if opts.Color != ColorAmber {
+		params.Set("color", opts.Color.String())
+	}
+	if len(opts.Styles) > 0 {
+		params.Set("style", formatStyles(opts.Styles))
+	}
+	if opts.Format != FormatMP4 {
+		params.Set("format", opts.Format.String())
+	}
+	if opts.Lang != "" {
+		params.Set("lang", opts.Lang)
+	}
+
+	return params
+}
@@ -0,0 +1,183 @@
+package dotmtx
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+)
+
+// Align selects the horizontal alignment of wrapped lines on a panel.
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+var alignNames = [...]string{"left", "center", "right"}
+
+func (a Align) String() string {
+	if a < 0 || int(a) >= len(alignNames) {
+		return fmt.Sprintf("Align(%d)", int(a))
+	}
+	return alignNames[a]
+}
+
+// Overflow selects what happens to static text that does not fit a panel.
+type Overflow int
+
+const (
+	OverflowPage Overflow = iota
+	OverflowScroll
+)
+
+var overflowNames = [...]string{"page", "scroll"}
+
+func (o Overflow) String() string {
+	if o < 0 || int(o) >= len(overflowNames) {
+		return fmt.Sprintf("Overflow(%d)", int(o))
+	}
+	return overflowNames[o]
+}
+
+// Panel limits and defaults
+const (
+	MaxPanelRows = 64
+
+	DefaultPageDwell   = 2
+	DefaultScrollSpeed = 4
+)
+
+// placeRows returns a copy of the dot matrix src with the given number
+// of rows, vertically centering the original content.
+func placeRows(src *image.Paletted, rows int) *image.Paletted {
+	dst := image.NewPaletted(image.Rect(0, 0, src.Rect.Dx(), rows), palette[:])
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(palette[1]), image.Point{}, draw.Src)
+
+	offset := (rows - src.Rect.Dy()) / 2
+	draw.Draw(dst, src.Rect.Add(image.Pt(0, offset)), src, src.Rect.Min, draw.Src)
+
+	return dst
+}
+
+// wrapText splits text into lines no wider than the given number of dots,
+// breaking at spaces when possible.
+func wrapText(text string, columns int) []string {
+	fits := func(s string) bool {
+		return font.MeasureString(Font, s).Ceil() <= columns
+	}
+
+	var lines []string
+	var line string
+
+	for _, word := range strings.Fields(text) {
+		if line != "" {
+			if fits(line + " " + word) {
+				line += " " + word
+				continue
+			}
+
+			lines = append(lines, line)
+			line = ""
+		}
+
+		// break words longer than a whole line
+		for !fits(word) {
+			cut := 0
+			for i, r := range word {
+				if i > 0 && !fits(word[:i+utf8.RuneLen(r)]) {
+					break
+				}
+				cut = i + utf8.RuneLen(r)
+			}
+
+			lines = append(lines, word[:cut])
+			word = word[cut:]
+		}
+
+		line = word
+	}
+
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// makePanelPages renders static text on a panel of fixed size, wrapping
+// and aligning lines. When the text needs more rows than the panel has,
+// it is split into pages or, depending on opts.Overflow, scrolled.
+func makePanelPages(ctx context.Context, opts Options) (*Animation, error) {
+	columns, rows := opts.PanelColumns, opts.PanelRows
+
+	lineHeight := Font.Metrics().Height.Ceil()
+	linesPerPage := max(1, rows/lineHeight)
+
+	lines := wrapText(opts.Text, columns)
+
+	if len(lines) > linesPerPage && opts.Overflow == OverflowScroll {
+		opts.Speed = DefaultScrollSpeed
+		return MakeAnimation(ctx, opts)
+	}
+
+	pageCount := (len(lines) + linesPerPage - 1) / linesPerPage
+
+	dots := opts.dots()
+	width := int(dots.Width(float64(columns)))
+	height := int(dots.Width(float64(rows)))
+
+	delay := int(math.Round(100 * opts.Dwell))
+	if delay <= 0 {
+		delay = 100 * DefaultPageDwell
+	}
+
+	anim := Animation{
+		Width:     width,
+		Height:    height,
+		Palette:   opts.Color.palette(),
+		Delay:     make([]int, pageCount),
+		LoopCount: 0,
+	}
+
+	if pageCount > 1 {
+		anim.LoopCount = gifLoopCount(opts.Loops)
+		for page := range anim.Delay {
+			anim.Delay[page] = delay
+		}
+	}
+
+	anim.draw = func(frame *image.Paletted, page int) {
+		pageLines := lines[page*linesPerPage : min(len(lines), (page+1)*linesPerPage)]
+
+		dotMatrix := image.NewPaletted(image.Rect(0, 0, columns, rows), palette[:])
+		for i := range dotMatrix.Pix {
+			dotMatrix.Pix[i] = 1
+		}
+
+		top := (rows - len(pageLines)*lineHeight) / 2
+
+		for i, line := range pageLines {
+			left := 0
+			switch opts.Align {
+			case AlignCenter:
+				left = (columns - font.MeasureString(Font, line).Ceil()) / 2
+			case AlignRight:
+				left = columns - font.MeasureString(Font, line).Ceil()
+			}
+
+			drawText(dotMatrix, left, top+i*lineHeight+Font.Metrics().Ascent.Ceil(), line)
+		}
+
+		drawDots(frame, dotMatrix, dots, func(x int) int { return x })
+	}
+
+	return &anim, nil
+}
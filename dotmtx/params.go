@@ -17,13 +17,43 @@ const (
 	DotSize      = DotInnerSize + 2*DotPadding
 )
 
+// Geometry is the size in pixels of a dot and of the padding around it.
+type Geometry struct {
+	Inner   int
+	Padding int
+}
+
+var DefaultGeometry = Geometry{DotInnerSize, DotPadding}
+
+// Size returns the distance in pixels between adjacent dots.
+func (g Geometry) Size() int {
+	return g.Inner + 2*g.Padding
+}
+
+// Width returns the size in pixels of a display with the given number of dots.
+func (g Geometry) Width(dots float64) float64 {
+	return dots*float64(g.Size()) + 2*float64(g.Padding)
+}
+
 // Resource limits
 const (
-	MaxChars = 100
-	MaxWidth = 10922
+	// frames are drawn one at a time from the dot matrix, so the text
+	// length is only bounded by the tape length and the encoding time
+	MaxChars = 256
+	// maximum width of frames in pixels, the largest accepted
+	// by common video encoders; only a few frames are kept in memory
+	MaxWidth = 1 << 14
+	// maximum length of the tape in dots, it bounds the number of frames
+	MaxTapeColumns = 1 << 16
+	// maximum number of pixels in all frames of one loop; GIFs are encoded
+	// at about 8ns per pixel on one core, so that the largest animations
+	// take a few seconds, well within the default render deadline.
+	// Longer texts are drawn with smaller dots to stay within the limit
+	MaxPixels = 1 << 29
 )
 
 var Font font.Face
+var fontChars map[rune]*bdf.Character
 var CharWidthInDots int
 var CharWidthInPixels int
 
@@ -38,6 +68,7 @@ func init() {
 	}
 
 	Font = bdfFont.NewFace()
+	fontChars = bdfFont.CharMap
 	advance, ok := Font.GlyphAdvance(bdfFont.DefaultChar)
 	if !ok {
 		advance = fixed.I(6)
@@ -45,4 +76,12 @@ func init() {
 
 	CharWidthInDots = advance.Ceil()
 	CharWidthInPixels = CharWidthInDots * DotSize
+
+	buildAtlas(bdfFont.DefaultChar)
+}
+
+// SupportedChar reports whether the font has a glyph for r.
+func SupportedChar(r rune) bool {
+	_, ok := fontChars[r]
+	return ok
 }
@@ -0,0 +1,58 @@
+package dotmtx
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"net/http"
+
+	"petbots.fbbdev.it/dotmtxbot/log"
+)
+
+//go:embed playground.html
+var playgroundHTML string
+var playgroundTemplate = template.Must(template.New("playground").Parse(playgroundHTML))
+
+type playgroundData struct {
+	GifPath     string
+	BotUsername string
+	MaxChars    int
+	MaxDwell    int
+	MaxLoops    int
+}
+
+// PlaygroundHandler returns a handler serving an HTML page with a form
+// for render parameters, a live preview from the GIF endpoint at gifPath
+// and the equivalent inline query for the given bot.
+func PlaygroundHandler(gifPath string, botUsername string) http.HandlerFunc {
+	data := playgroundData{
+		GifPath:     gifPath,
+		BotUsername: botUsername,
+		MaxChars:    MaxChars,
+		MaxDwell:    MaxDwell,
+		MaxLoops:    MaxLoops,
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var page bytes.Buffer
+		if err := playgroundTemplate.Execute(&page, data); err != nil {
+			log.ErrorLogger.Print("template: ", err)
+			log.WarningLogger.Print("could not render playground page")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/html; charset=utf-8")
+		w.Write(page.Bytes())
+	}
+}
@@ -0,0 +1,175 @@
+package dotmtx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MaxVideoScale is the largest upscale factor a profile may request
+const MaxVideoScale = 8
+
+// Profile is a named set of ffmpeg output settings for videos.
+type Profile struct {
+	Name string
+	// Extension is the file extension of the container, without the dot
+	Extension   string
+	ContentType string
+	// Scale enlarges frames by the given factor with nearest-neighbor
+	// sampling, so that recompression keeps the edges of dots sharp
+	Scale int
+	// Args are the ffmpeg output options, without the output file
+	Args []string
+}
+
+// defaultProfiles are used when no profile file is available;
+// they match the historical ffmpeg invocation.
+var defaultProfiles = []Profile{{
+	Name:        "mp4",
+	Extension:   "mp4",
+	ContentType: "video/mp4",
+	Scale:       1,
+	Args:        []string{"-movflags", "+faststart", "-pix_fmt", "yuv420p"},
+}}
+
+var profiles = defaultProfiles
+
+var errProfileSyntax = errors.New("expected [name] or key = value")
+
+// LoadProfiles reads video profiles from the file at path; when the file
+// does not exist, a single mp4 profile with default settings is used.
+//
+// Profiles start with their name in square brackets, followed by
+// key = value lines for extension, type, scale and args; args are split
+// at spaces. Lines starting with # are comments. The first profile
+// for each extension is the default for URLs with that extension.
+func LoadProfiles(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		profiles = defaultProfiles
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var loaded []Profile
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			loaded = append(loaded, Profile{Name: strings.TrimSpace(line[1 : len(line)-1]), Scale: 1})
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || len(loaded) == 0 {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, errProfileSyntax)
+		}
+
+		profile := &loaded[len(loaded)-1]
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "extension":
+			profile.Extension = value
+		case "type":
+			profile.ContentType = value
+		case "scale":
+			if profile.Scale, err = strconv.Atoi(value); err != nil || profile.Scale < 1 || profile.Scale > MaxVideoScale {
+				return fmt.Errorf("%s:%d: scale must be between 1 and %d", path, lineNumber, MaxVideoScale)
+			}
+		case "args":
+			profile.Args = strings.Fields(value)
+		default:
+			return fmt.Errorf("%s:%d: unknown key %q", path, lineNumber, strings.TrimSpace(key))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for _, profile := range loaded {
+		if profile.Name == "" || names[profile.Name] {
+			return fmt.Errorf("%s: missing or duplicate profile name %q", path, profile.Name)
+		}
+		if profile.Extension == "" || profile.ContentType == "" || len(profile.Args) == 0 {
+			return fmt.Errorf("%s: profile %s needs extension, type and args", path, profile.Name)
+		}
+		names[profile.Name] = true
+	}
+
+	if len(loaded) == 0 {
+		return fmt.Errorf("%s: no profiles", path)
+	}
+
+	profiles = loaded
+	return nil
+}
+
+// Profiles returns the available video profiles.
+func Profiles() []Profile {
+	return profiles
+}
+
+// selectProfile returns the profile named by the profile parameter,
+// or else the first profile for the extension of urlPath; it reports
+// false when there is no such profile.
+func selectProfile(name string, urlPath string) (Profile, bool) {
+	if name != "" {
+		for _, profile := range profiles {
+			if profile.Name == name {
+				return profile, true
+			}
+		}
+		return Profile{}, false
+	}
+
+	ext := strings.TrimPrefix(path.Ext(urlPath), ".")
+	for _, profile := range profiles {
+		if profile.Extension == ext {
+			return profile, true
+		}
+	}
+
+	return Profile{}, false
+}
+
+// FormatAvailable reports whether animations can be
+// delivered in the given format.
+func FormatAvailable(f Format) bool {
+	if !f.Video() {
+		return true
+	}
+
+	_, ok := selectProfile("", "."+f.String())
+	return ok && VideoAvailable()
+}
+
+// encoder returns the video encoder selected by the profile arguments,
+// or an empty string when ffmpeg should choose.
+func (p Profile) encoder() string {
+	for i := 0; i+1 < len(p.Args); i++ {
+		switch p.Args[i] {
+		case "-c:v", "-codec:v", "-vcodec":
+			return p.Args[i+1]
+		}
+	}
+	return ""
+}
+
+// key identifies the output of the profile, for caching purposes.
+func (p Profile) key() string {
+	return fmt.Sprintf("video %s %s %d %s", p.Name, p.Extension, p.Scale, strings.Join(p.Args, " "))
+}
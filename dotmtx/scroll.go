@@ -0,0 +1,370 @@
+package dotmtx
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// scroll describes a scrolling animation as a sequence of positions
+// of a window sliding over the dot matrix tape. Frames are drawn
+// on demand from the dot matrix, one window at a time.
+type scroll struct {
+	dotMatrix *image.Paletted
+	dots      Geometry
+	palette   color.Palette
+	// tape columns the window slides over, starting from tapeStart
+	tapeStart   int
+	tapeColumns int
+	// window size in pixels
+	width  int
+	height int
+
+	textColumns   int
+	windowColumns int
+	// number of columns after which the tape repeats; 0 when bouncing
+	loopColumns int
+
+	// window positions as tape columns; a single position means a still image
+	columns []int
+	// time in centiseconds spent still at each position
+	pauses []float64
+	// time in centiseconds spent moving from each position to the next one
+	moves []float64
+}
+
+func (s *scroll) static() bool {
+	return len(s.columns) == 1
+}
+
+// isBlank reports whether the window at the given position shows no text.
+func (s *scroll) isBlank(column int) bool {
+	return s.loopColumns > 0 && column >= s.textColumns && column+s.windowColumns <= s.loopColumns
+}
+
+// sourceColumn maps tape columns to dot matrix columns; -1 means blank.
+func (s *scroll) sourceColumn(x int) int {
+	if s.loopColumns > 0 {
+		x %= s.loopColumns
+	}
+	if x < 0 || x >= s.textColumns {
+		return -1
+	}
+	return x
+}
+
+// window returns the frame whose left edge is x pixels away
+// from the beginning of the tape.
+func (s *scroll) window(x int) *image.Paletted {
+	frame := newFrame(s.width, s.height, s.palette)
+	s.drawWindow(frame, x)
+	return frame
+}
+
+// drawWindow draws into frame, which must be blank and as big
+// as the window, the dots visible x pixels away from the beginning
+// of the tape; the result is the same as cutting the window out
+// of the whole tape drawn by drawDots.
+func (s *scroll) drawWindow(frame *image.Paletted, x int) {
+	size, padding := s.dots.Size(), s.dots.Padding
+	tiles := s.dots.tiles()
+
+	// the window starts skip pixels into the given dot, or after
+	// some pixels of blank margin when offset is negative
+	offset := x - s.tapeStart*size - 2*padding
+	start, column, skip := 0, 0, 0
+	if offset < 0 {
+		start = -offset
+	} else {
+		column, skip = offset/size, offset%size
+	}
+
+	rows := min(s.dotMatrix.Rect.Dy(), (s.height-2*padding)/size)
+
+	for y := 0; y < rows; y++ {
+		top := (2*padding + y*size) * frame.Stride
+		row := frame.Pix[top : top+s.width]
+		src := s.dotMatrix.Pix[y*s.dotMatrix.Stride:]
+
+		for i, c, from := start, column, skip; i < s.width && c < s.tapeColumns; i, c, from = i+size-from, c+1, 0 {
+			dotState := uint8(1)
+			if sx := s.sourceColumn(s.tapeStart + c); sx >= 0 {
+				dotState = src[sx]
+			}
+
+			copy(row[i:], tiles[dotState][from:])
+		}
+
+		for dy := 1; dy < s.dots.Inner; dy++ {
+			copy(frame.Pix[top+dy*frame.Stride:], row)
+		}
+	}
+}
+
+// easeDelays spreads the duration of a pass of n frames, each lasting delay
+// on average, according to the given easing function.
+func easeDelays(n int, delay float64, easing Easing) []float64 {
+	// inverse easing functions: time at which position p is reached
+	var inverse func(p float64) float64
+
+	switch easing {
+	case EaseIn:
+		inverse = func(p float64) float64 { return 2 * math.Acos(1-p) / math.Pi }
+	case EaseOut:
+		inverse = func(p float64) float64 { return 2 * math.Asin(p) / math.Pi }
+	case EaseInOut:
+		inverse = func(p float64) float64 { return math.Acos(1-2*p) / math.Pi }
+	default:
+		inverse = func(p float64) float64 { return p }
+	}
+
+	delays := make([]float64, n)
+	duration := float64(n) * delay
+
+	for i := range delays {
+		start := inverse(float64(i) / float64(n))
+		end := inverse(float64(i+1) / float64(n))
+		delays[i] = duration * (end - start)
+	}
+
+	return delays
+}
+
+// tapeLayout describes the position of text and window on the tape, in dots.
+// When looping, the tape repeats every loopColumns columns; when bouncing,
+// the left edge of the window moves between runStart and runEnd.
+type tapeLayout struct {
+	panel  bool
+	bounce bool
+	static bool
+
+	rows int
+
+	textColumns   float64
+	windowColumns float64
+	loopColumns   float64
+	runStart      float64
+	runEnd        float64
+}
+
+// measureTape computes the tape layout for a text of the given width in dots.
+func measureTape(opts Options, textColumns int) (tape tapeLayout) {
+	tape.panel = opts.PanelColumns > 0 && opts.PanelRows > 0
+	tape.bounce = opts.Bounce && opts.Speed != 0
+	tape.static = opts.Speed == 0
+	tape.rows = Font.Metrics().Height.Ceil()
+
+	width, blank := math.Min(opts.Width, WidthLimit(opts)), opts.Blank
+	if opts.Speed == 0 {
+		blank = math.Max(0, width-1)
+	}
+
+	tape.textColumns = float64(textColumns)
+	tape.windowColumns = math.Round(width * tape.textColumns)
+	tape.loopColumns = math.Round((1 + blank) * tape.textColumns)
+
+	if tape.panel {
+		tape.rows = opts.PanelRows
+		tape.windowColumns = float64(opts.PanelColumns)
+		tape.loopColumns = math.Max(tape.loopColumns, tape.windowColumns)
+	}
+
+	tape.runStart = math.Min(0, tape.textColumns-tape.windowColumns)
+	tape.runEnd = math.Max(0, tape.textColumns-tape.windowColumns)
+
+	return
+}
+
+// backingColumns returns the number of tape columns the window slides over.
+// It bounds the number of frames of the animation.
+func (tape tapeLayout) backingColumns() float64 {
+	if tape.bounce {
+		return tape.runEnd - tape.runStart + tape.windowColumns
+	}
+	return tape.loopColumns + tape.windowColumns
+}
+
+// steps returns the number of window positions in one loop.
+func (tape tapeLayout) steps() float64 {
+	if tape.bounce {
+		return 2 * (tape.runEnd - tape.runStart)
+	}
+	return tape.loopColumns
+}
+
+// dwellCount returns the number of pauses in one loop.
+func (tape tapeLayout) dwellCount() int {
+	switch {
+	case tape.bounce:
+		return 2
+	case tape.windowColumns >= tape.textColumns:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// makeScroll lays out the tape for a scrolling animation and computes
+// the sequence of window positions and their exact timing.
+// It returns errWidthOverflow when the window or the tape would be too big.
+func makeScroll(opts Options) (*scroll, error) {
+	dotMatrix, err := drawDotMatrix(opts.Text, opts.Styles)
+	if err != nil {
+		return nil, err
+	}
+
+	// log.InfoLogger.Print("text rendering done")
+
+	dotMatrixWidth := dotMatrix.Rect.Dx()
+	dotMatrixHeight := dotMatrix.Rect.Dy()
+
+	tape := measureTape(opts, dotMatrixWidth)
+	speed, bounce := opts.Speed, tape.bounce
+
+	// panels have a fixed size, the text is vertically centered
+	if tape.panel {
+		dotMatrix = placeRows(dotMatrix, opts.PanelRows)
+		dotMatrixHeight = opts.PanelRows
+	}
+
+	dots := opts.dots()
+
+	windowColumns := tape.windowColumns
+	windowWidth := dots.Width(windowColumns)
+
+	loopColumns := tape.loopColumns
+	runStart, runEnd := tape.runStart, tape.runEnd
+
+	tapeStart := 0.0
+	if bounce {
+		tapeStart = runStart
+	}
+
+	tapeColumns := tape.backingColumns()
+	windowHeight := int(dots.Width(float64(dotMatrixHeight)))
+
+	// handle overflows
+	if !tape.fits(dots) {
+		return nil, errWidthOverflow
+	}
+
+	// log.InfoLogger.Print("size is valid", dotMatrixWidth, dotMatrixHeight, windowColumns, windowWidth, tapeColumns)
+
+	reverse := speed < 0
+	if reverse {
+		speed = -speed
+	}
+
+	delay := 100 / (speed * float64(CharWidthInDots))
+	if speed == 0 {
+		delay = 0
+	}
+
+	// handle overflows
+	if math.IsNaN(delay) || math.IsInf(delay, 0) || delay < 0 || delay > math.MaxUint16 {
+		delay = 0
+	}
+
+	// log.InfoLogger.Print("timing is valid")
+
+	// nothing to scroll
+	if loopColumns == 0 || (bounce && runStart == runEnd) {
+		delay = 0
+	}
+
+	s := &scroll{
+		dotMatrix:     dotMatrix,
+		dots:          dots,
+		palette:       opts.Color.palette(),
+		tapeStart:     int(tapeStart),
+		tapeColumns:   int(tapeColumns),
+		width:         int(windowWidth),
+		height:        windowHeight,
+		textColumns:   dotMatrixWidth,
+		windowColumns: int(windowColumns),
+		loopColumns:   int(loopColumns),
+	}
+
+	if bounce {
+		s.loopColumns = 0
+	}
+
+	if delay == 0 {
+		s.columns = []int{0}
+		s.pauses = []float64{0}
+		s.moves = []float64{0}
+		return s, nil
+	}
+
+	// determine the sequence of window positions and their timing
+	var dwellColumns []int
+
+	if bounce {
+		start, end, step := int(runStart), int(runEnd), 1
+		if reverse {
+			start, end, step = end, start, -1
+		}
+
+		for column := start; column != end; column += step {
+			s.columns = append(s.columns, column)
+		}
+		for column := end; column != start; column -= step {
+			s.columns = append(s.columns, column)
+		}
+
+		legMoves := easeDelays(len(s.columns)/2, delay, opts.Easing)
+		s.moves = append(legMoves, legMoves...)
+
+		dwellColumns = []int{start, end}
+	} else {
+		var column, step int
+
+		if reverse {
+			column = int(math.Min(loopColumns, float64(dotMatrixWidth)+windowColumns) - windowColumns)
+			step = -1
+		} else {
+			column = max(dotMatrixWidth, int(loopColumns-windowColumns))
+			step = 1
+		}
+
+		for range int(loopColumns) {
+			s.columns = append(s.columns, column)
+
+			column += step
+			if column >= int(loopColumns) {
+				column -= int(loopColumns)
+			} else if column < 0 {
+				column += int(loopColumns)
+			}
+		}
+
+		s.moves = easeDelays(len(s.columns), delay, opts.Easing)
+
+		// pause with the text centered in the window
+		if int(windowColumns) >= dotMatrixWidth {
+			centered := int(loopColumns) - (int(windowColumns)-dotMatrixWidth)/2
+			dwellColumns = []int{centered % int(loopColumns)}
+		}
+	}
+
+	s.pauses = make([]float64, len(s.columns))
+
+	if opts.Dwell > 0 {
+		for i, column := range s.columns {
+			for _, dwellColumn := range dwellColumns {
+				if column == dwellColumn {
+					s.pauses[i] = math.Round(100 * opts.Dwell)
+				}
+			}
+		}
+	}
+
+	// apply start phase
+	if shift := int(math.Round(opts.Phase*float64(len(s.columns)))) % len(s.columns); shift > 0 {
+		s.columns = append(s.columns[shift:], s.columns[:shift]...)
+		s.pauses = append(s.pauses[shift:], s.pauses[:shift]...)
+		s.moves = append(s.moves[shift:], s.moves[:shift]...)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,176 @@
+package dotmtx
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// Style is a set of text decorations.
+type Style uint8
+
+const (
+	// StyleBold draws glyphs with a double stroke.
+	StyleBold Style = 1 << iota
+	// StyleItalic shears glyphs to the right.
+	StyleItalic
+	// StyleUnderline lights the bottom row under the text.
+	StyleUnderline
+	// StyleStrike lights a row across the middle of lowercase letters.
+	StyleStrike
+	// StyleCode draws the text in inverse video.
+	StyleCode
+)
+
+// styleLetters encodes each style flag in query parameters
+var styleLetters = [...]byte{'b', 'i', 'u', 's', 'c'}
+
+func (s Style) String() string {
+	var letters []byte
+	for i, letter := range styleLetters {
+		if s&(1<<i) != 0 {
+			letters = append(letters, letter)
+		}
+	}
+	return string(letters)
+}
+
+// MaxStyleSpans is the maximum number of style spans in a text
+const MaxStyleSpans = MaxChars
+
+// StyleSpan applies a style to the characters of the text
+// from Start (included) to End (excluded), counted in runes.
+type StyleSpan struct {
+	Start int
+	End   int
+	Style Style
+}
+
+// parseStyles decodes a comma separated list of spans
+// in the form start-end followed by style letters, like 0-5b,3-8iu.
+func parseStyles(value string) ([]StyleSpan, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(value, ",")
+	if len(fields) > MaxStyleSpans {
+		return nil, ErrInvalidOption
+	}
+
+	spans := make([]StyleSpan, len(fields))
+
+	for i, field := range fields {
+		letters := strings.TrimLeft(field, "0123456789-")
+		bounds := strings.TrimSuffix(field, letters)
+
+		start, end, ok := strings.Cut(bounds, "-")
+		if !ok || letters == "" {
+			return nil, ErrInvalidOption
+		}
+
+		var err error
+		if spans[i].Start, err = strconv.Atoi(start); err != nil {
+			return nil, ErrInvalidOption
+		}
+		if spans[i].End, err = strconv.Atoi(end); err != nil {
+			return nil, ErrInvalidOption
+		}
+
+		for _, letter := range []byte(letters) {
+			flag := strings.IndexByte(string(styleLetters[:]), letter)
+			if flag < 0 {
+				return nil, ErrInvalidOption
+			}
+			spans[i].Style |= 1 << flag
+		}
+
+		if spans[i].Start < 0 || spans[i].End <= spans[i].Start {
+			return nil, ErrInvalidOption
+		}
+	}
+
+	return spans, nil
+}
+
+// formatStyles encodes spans in the format read by parseStyles.
+func formatStyles(spans []StyleSpan) string {
+	fields := make([]string, len(spans))
+	for i, span := range spans {
+		fields[i] = fmt.Sprintf("%d-%d%v", span.Start, span.End, span.Style)
+	}
+	return strings.Join(fields, ",")
+}
+
+// applyStyles decorates the glyphs of a dot matrix; bounds holds
+// the first and last column plus one of each rune of the text.
+func applyStyles(img *image.Paletted, bounds [][2]int, spans []StyleSpan) {
+	const lit, unlit = 2, 1
+
+	rows := img.Rect.Dy()
+	baseline := Font.Metrics().Ascent.Ceil()
+
+	// strike through the middle of lowercase letters
+	strikeRow := baseline - 1
+	if xBounds, _, ok := Font.GlyphBounds('x'); ok {
+		strikeRow = baseline + xBounds.Min.Y.Floor()/2
+	}
+
+	for i, columns := range bounds {
+		var style Style
+		for _, span := range spans {
+			if i >= span.Start && i < span.End {
+				style |= span.Style
+			}
+		}
+
+		if style == 0 {
+			continue
+		}
+
+		x0, x1 := columns[0], columns[1]
+
+		for y := 0; y < rows; y++ {
+			row := img.Pix[y*img.Stride+x0 : y*img.Stride+x1]
+
+			if style&StyleBold != 0 {
+				// going right to left reads the original dots
+				for x := len(row) - 1; x > 0; x-- {
+					if row[x-1] == lit {
+						row[x] = lit
+					}
+				}
+			}
+
+			if style&StyleItalic != 0 {
+				// the upper half moves right by one dot, descenders move left
+				shift := (baseline - 1 - y) * 2 / baseline
+				if y >= baseline {
+					shift = -1
+				}
+
+				shifted := make([]uint8, len(row))
+				for x := range shifted {
+					shifted[x] = unlit
+					if sx := x - shift; sx >= 0 && sx < len(row) {
+						shifted[x] = row[sx]
+					}
+				}
+				copy(row, shifted)
+			}
+
+			if (style&StyleUnderline != 0 && y == rows-1) || (style&StyleStrike != 0 && y == strikeRow) {
+				for x := range row {
+					row[x] = lit
+				}
+			}
+
+			if style&StyleCode != 0 {
+				for x := range row {
+					row[x] = lit + unlit - row[x]
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package dotmtx
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseStyles(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected []StyleSpan
+	}{
+		{"", nil},
+		{"0-5b", []StyleSpan{{0, 5, StyleBold}}},
+		{"0-5b,3-8iu", []StyleSpan{{0, 5, StyleBold}, {3, 8, StyleItalic | StyleUnderline}}},
+		{"2-3scbiu", []StyleSpan{{2, 3, StyleBold | StyleItalic | StyleUnderline | StyleStrike | StyleCode}}},
+		{"10-250c", []StyleSpan{{10, 250, StyleCode}}},
+	}
+
+	for _, c := range cases {
+		actual, err := parseStyles(c.value)
+		if err != nil || !slices.Equal(actual, c.expected) {
+			t.Errorf("parseStyles(%q) = %v, %v; expected %v, nil", c.value, actual, err, c.expected)
+		}
+
+		// formatStyles sorts the letters of each span
+		if c.value != "2-3scbiu" {
+			if formatted := formatStyles(actual); formatted != c.value {
+				t.Errorf("formatStyles(%v) = %q; expected %q", actual, formatted, c.value)
+			}
+		}
+	}
+}
+
+func TestParseStylesInvalid(t *testing.T) {
+	for _, value := range []string{
+		",",
+		"0-5",
+		"b",
+		"5b",
+		"0-5x",
+		"0-5B",
+		"-3-5b",
+		"3--5b",
+		"5-5b",
+		"5-3b",
+		"0-5b,",
+		"0-5 b",
+		strings.Repeat("0-1b,", MaxStyleSpans) + "0-1b",
+	} {
+		if spans, err := parseStyles(value); err != ErrInvalidOption {
+			t.Errorf("parseStyles(%q) = %v, %v; expected %v", value, spans, err, ErrInvalidOption)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package dotmtx
+
+import (
+	"context"
+	"image"
+	"image/color"
+
+	"golang.org/x/image/font"
+)
+
+// MaxThumbSize is the maximum width and height of thumbnails in pixels
+const MaxThumbSize = 320
+
+// textFullyVisible reports whether the window at the given
+// position shows the whole text.
+func (s *scroll) textFullyVisible(column int) bool {
+	if s.textColumns > s.windowColumns {
+		return false
+	}
+
+	// the text starts at tape column 0 and, when looping, every loopColumns
+	starts := []int{0}
+	if s.loopColumns > 0 {
+		starts = append(starts, s.loopColumns)
+	}
+
+	for _, start := range starts {
+		if column <= start && start+s.textColumns <= column+s.windowColumns {
+			return true
+		}
+	}
+
+	return false
+}
+
+// thumbnailFrame returns the first frame showing the whole text or,
+// when the text never fits the window, the frame where it starts.
+func thumbnailFrame(ctx context.Context, opts Options) (*image.Paletted, error) {
+	if opts.PanelColumns > 0 && opts.PanelRows > 0 && opts.Speed == 0 {
+		anim, err := makePanelPages(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return anim.Frame(0), nil
+	}
+
+	s, err := makeScroll(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.static() {
+		return s.window(s.columns[0] * s.dots.Size()), nil
+	}
+
+	for _, column := range s.columns {
+		if s.textFullyVisible(column) {
+			return s.window(column * s.dots.Size()), nil
+		}
+	}
+
+	return s.window(0), nil
+}
+
+// thumbnailGeometry returns the largest dot geometry that keeps
+// the frames of opts within MaxThumbSize, or the smallest one
+// that fits within resource limits when none does.
+func thumbnailGeometry(opts Options) (Geometry, bool) {
+	rows := Font.Metrics().Height.Ceil()
+	if opts.PanelColumns > 0 && opts.PanelRows > 0 {
+		rows = opts.PanelRows
+	}
+
+	textColumns := font.MeasureString(Font, opts.Text).Ceil()
+	windowColumns := measureTape(opts, textColumns).windowColumns
+
+	smallest, found := Geometry{}, false
+
+	for _, dots := range fallbackGeometries {
+		if !fits(opts, dots) {
+			continue
+		}
+
+		if dots.Width(windowColumns) <= MaxThumbSize && dots.Width(float64(rows)) <= MaxThumbSize {
+			return dots, true
+		}
+
+		smallest, found = dots, true
+	}
+
+	return smallest, found
+}
+
+// MakeThumbnail renders a still image representative of the animation
+// described by opts, no larger than MaxThumbSize pixels on either side.
+// Smaller dots are used first; when they are not enough, the frame
+// is scaled down. It gives up with the context error when ctx is cancelled.
+func MakeThumbnail(ctx context.Context, opts Options) (image.Image, error) {
+	opts, _, ok := fit(opts)
+	if !ok {
+		return MakeThumbnail(ctx, ErrorOptions(errTooBig, opts))
+	}
+
+	if opts.geometry, ok = thumbnailGeometry(opts); !ok {
+		return MakeThumbnail(ctx, ErrorOptions(errTooBig, opts))
+	}
+
+	frame, err := thumbnailFrame(ctx, opts)
+	if err != nil {
+		if err == errWidthOverflow {
+			return MakeThumbnail(ctx, ErrorOptions(errTooBig, opts))
+		}
+
+		return nil, err
+	}
+
+	return shrink(frame, MaxThumbSize), nil
+}
+
+// shrink scales img down, averaging pixels, so that
+// neither side exceeds size; smaller images are returned as they are.
+func shrink(img *image.Paletted, size int) image.Image {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	scale := float64(max(w, h)) / float64(size)
+	dw, dh := max(1, int(float64(w)/scale)), max(1, int(float64(h)/scale))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max(y*h/dh+1, (y+1)*h/dh)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max(x*w/dw+1, (x+1)*w/dw)
+
+			var r, g, b, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, _ := img.At(img.Rect.Min.X+sx, img.Rect.Min.Y+sy).RGBA()
+					r, g, b, n = r+cr, g+cg, b+cb, n+1
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), 0xffff})
+		}
+	}
+
+	return dst
+}
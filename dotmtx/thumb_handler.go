@@ -0,0 +1,54 @@
+package dotmtx
+
+import (
+	"bytes"
+	"image/jpeg"
+	"net/http"
+
+	"petbots.fbbdev.it/dotmtxbot/log"
+)
+
+func ThumbHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+
+	status := http.StatusOK
+
+	// invalid parameters get a thumbnail explaining what is wrong
+	opts, err := ParseOptions(r.URL.Query())
+	if err != nil {
+		opts, status = ErrorOptions(err, opts), http.StatusBadRequest
+	}
+
+	if checkCache(w, r, "jpeg", opts, status) {
+		return
+	}
+
+	ctx, cancel := renderContext(r)
+	defer cancel()
+
+	thumb, err := MakeThumbnail(ctx, opts)
+	if renderCancelled(w, r, err) {
+		return
+	} else if err != nil {
+		log.ErrorLogger.Print("MakeThumbnail: ", err)
+		log.WarningLogger.Print("thumbnail generation failed")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85}); err != nil {
+		log.ErrorLogger.Print("jpeg: ", err)
+		log.WarningLogger.Print("could not encode thumbnail")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	if err := serveRender(w, r, status, bytes.NewReader(buf.Bytes())); err != nil {
+		log.ErrorLogger.Print("http: ", err)
+		log.WarningLogger.Print("could not write http response")
+	}
+}
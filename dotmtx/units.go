@@ -0,0 +1,128 @@
+package dotmtx
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+)
+
+// Speed keywords, in characters per second
+var speedKeywords = map[string]float64{
+	"slow":   2,
+	"normal": 4,
+	"fast":   8,
+}
+
+var quantityRe = regexp.MustCompile(`^([-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?)([a-z]*)$`)
+
+// parseQuantity splits a string like 30dps into its value and unit.
+func parseQuantity(s string) (value float64, unit string, ok bool) {
+	match := quantityRe.FindStringSubmatch(strings.ToLower(s))
+	if match == nil {
+		return 0, "", false
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil || math.IsInf(value, 0) {
+		return 0, "", false
+	}
+
+	return value, match[2], true
+}
+
+// parseSize resolves a size into a multiplier of the text width.
+// Sizes are bare multipliers or carry one of the units d (dots)
+// and px (pixels).
+func parseSize(s string, textColumns int) (float64, bool) {
+	value, unit, ok := parseQuantity(s)
+	if !ok {
+		return 0, false
+	}
+
+	columns := float64(max(1, textColumns))
+
+	switch unit {
+	case "", "x":
+		return value, true
+	case "d":
+		return value / columns, true
+	case "px":
+		return math.Max(0, value-2*DotPadding) / DotSize / columns, true
+	default:
+		return 0, false
+	}
+}
+
+// parseSpeed resolves a speed into characters per second. Speeds are bare
+// numbers, keywords or carry one of the units cps (characters per second),
+// dps (dots per second) and s (duration of one loop). A leading minus sign
+// reverses the scrolling direction.
+func parseSpeed(s string, opts Options, textColumns int) (float64, bool) {
+	sign := 1.0
+	if keyword, found := strings.CutPrefix(strings.ToLower(s), "-"); found {
+		sign, s = -1, keyword
+	}
+
+	if speed, ok := speedKeywords[s]; ok {
+		return sign * speed, true
+	}
+
+	value, unit, ok := parseQuantity(s)
+	if !ok {
+		return 0, false
+	}
+
+	value *= sign
+
+	switch unit {
+	case "", "cps":
+		return value, true
+	case "dps":
+		return value / float64(CharWidthInDots), true
+	case "s":
+		if value == 0 {
+			return 0, false
+		}
+
+		// compute the speed that fits the whole loop, pauses excluded,
+		// into the given duration
+		opts.Speed = 1
+		tape := measureTape(opts, textColumns)
+		moving := math.Abs(value) - opts.Dwell*float64(tape.dwellCount())
+
+		if tape.steps() == 0 || !(moving > 0) {
+			return 0, false
+		}
+
+		return math.Copysign(tape.steps()/(float64(CharWidthInDots)*moving), value), true
+	default:
+		return 0, false
+	}
+}
+
+// SetParams resolves the mandatory parameters speed, width and blank,
+// which may carry units, into the values expected by MakeGif.
+// Since some units depend on the text and the other options,
+// it must be called after setting them.
+func (opts *Options) SetParams(speed string, width string, blank string) error {
+	textColumns := font.MeasureString(Font, opts.Text).Ceil()
+
+	var ok bool
+
+	if opts.Width, ok = parseSize(width, textColumns); !ok || !(opts.Width > 0) {
+		return ErrInvalidWidth
+	}
+
+	if opts.Blank, ok = parseSize(blank, textColumns); !ok || !(opts.Blank >= 0) {
+		return ErrInvalidBlank
+	}
+
+	if opts.Speed, ok = parseSpeed(speed, *opts, textColumns); !ok {
+		return ErrInvalidSpeed
+	}
+
+	return nil
+}
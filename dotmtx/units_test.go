@@ -0,0 +1,99 @@
+package dotmtx
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		size     string
+		expected float64
+		ok       bool
+	}{
+		{"1", 1, true},
+		{"0.5", 0.5, true},
+		{".5x", 0.5, true},
+		{"2E0", 2, true},
+		{"15d", 0.5, true},
+		{"30D", 1, true},
+		{"242px", 1, true},
+		{"1px", 0, true},
+		{"-1", -1, true},
+		{"", 0, false},
+		{"1.5.2", 0, false},
+		{"10cm", 0, false},
+		{"1e999", 0, false},
+	}
+
+	// a text of 30 dots, like HELLO
+	for _, c := range cases {
+		actual, ok := parseSize(c.size, 30)
+		if ok != c.ok || (ok && math.Abs(actual-c.expected) > 1e-9) {
+			t.Errorf("parseSize(%q) = %v, %v; expected %v, %v", c.size, actual, ok, c.expected, c.ok)
+		}
+	}
+}
+
+func TestParseSpeed(t *testing.T) {
+	cases := []struct {
+		speed    string
+		opts     Options
+		expected float64
+		ok       bool
+	}{
+		{"4", Options{Width: 1, Blank: 1}, 4, true},
+		{"+4", Options{Width: 1, Blank: 1}, 4, true},
+		{"-2.5cps", Options{Width: 1, Blank: 1}, -2.5, true},
+		{"30dps", Options{Width: 1, Blank: 1}, 5, true},
+		{"fast", Options{Width: 1, Blank: 1}, 8, true},
+		{"-Slow", Options{Width: 1, Blank: 1}, -2, true},
+		// 60 steps in one loop
+		{"2s", Options{Width: 1, Blank: 1}, 5, true},
+		{"-5s", Options{Width: 1, Blank: 1}, -2, true},
+		// one pause of one second when the text is fully visible
+		{"3s", Options{Width: 1, Blank: 1, Dwell: 1}, 5, true},
+		{"1s", Options{Width: 1, Blank: 1, Dwell: 1}, 0, false},
+		{"0s", Options{Width: 1, Blank: 1}, 0, false},
+		{"fastest", Options{Width: 1, Blank: 1}, 0, false},
+		{"4mph", Options{Width: 1, Blank: 1}, 0, false},
+	}
+
+	// a text of 30 dots, like HELLO
+	for _, c := range cases {
+		actual, ok := parseSpeed(c.speed, c.opts, 30)
+		if ok != c.ok || (ok && math.Abs(actual-c.expected) > 1e-9) {
+			t.Errorf("parseSpeed(%q) = %v, %v; expected %v, %v", c.speed, actual, ok, c.expected, c.ok)
+		}
+	}
+}
+
+func TestLoopDuration(t *testing.T) {
+	cases := []struct {
+		speed, width, blank, text string
+		expected                  time.Duration
+	}{
+		{"2s", "1", "1", "HELLO", 2 * time.Second},
+		{"1s", "1", "1", "HELLO", time.Second},
+		{"2s", "0.5", "1", "HELLO WORLD", 2 * time.Second},
+		{"1.5s", "64d", "0", "HELLO WORLD", 1500 * time.Millisecond},
+	}
+
+	for _, c := range cases {
+		opts := Options{Text: c.text, Format: FormatGIF}
+		if err := opts.SetParams(c.speed, c.width, c.blank); err != nil {
+			t.Fatal("SetParams: ", err)
+		}
+
+		info, err := Describe(context.Background(), opts)
+		if err != nil {
+			t.Fatal("Describe: ", err)
+		}
+
+		if info.Duration != c.expected {
+			t.Errorf("%s %s %s %s lasts %v; expected %v", c.speed, c.width, c.blank, c.text, info.Duration, c.expected)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package dotmtx
+
+import (
+	"context"
+	"image"
+	"io"
+	"math"
+	"sort"
+)
+
+// Video limits
+const (
+	MaxFPS           = 60
+	MaxVideoDuration = 120
+)
+
+// Video is a scrolling animation sampled at a constant frame rate.
+type Video struct {
+	Width      int
+	Height     int
+	FPS        int
+	FrameCount int
+
+	scroll *scroll
+	smooth bool
+	// start time of each scroll position in centiseconds,
+	// the last element is the total duration
+	starts []float64
+}
+
+// MakeVideo samples the scrolling animation described by opts
+// at opts.FPS frames per second. The loop duration is stretched
+// by less than half a frame so that the video loops seamlessly.
+// It returns nil when opts.FPS is zero or when the animation cannot
+// be sampled at a constant rate (still images, panel pages, images
+// too big); in that case the output of MakeGif should be used instead.
+func MakeVideo(opts Options) (*Video, error) {
+	if opts.FPS <= 0 || (opts.PanelColumns > 0 && opts.PanelRows > 0 && opts.Speed == 0) {
+		return nil, nil
+	}
+
+	opts, _, ok := fit(opts)
+	if !ok {
+		return nil, nil
+	}
+
+	s, err := makeScroll(opts)
+	if err != nil {
+		if err == errWidthOverflow {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if s.static() {
+		return nil, nil
+	}
+
+	starts := make([]float64, len(s.columns)+1)
+	for i := range s.columns {
+		starts[i+1] = starts[i] + s.pauses[i] + s.moves[i]
+	}
+
+	duration := starts[len(s.columns)] / 100
+	if duration > MaxVideoDuration {
+		return nil, nil
+	}
+
+	return &Video{
+		Width:      s.width,
+		Height:     s.height,
+		FPS:        opts.FPS,
+		FrameCount: max(1, int(math.Round(duration*float64(opts.FPS)))),
+		scroll:     s,
+		smooth:     opts.Smooth,
+		starts:     starts,
+	}, nil
+}
+
+// Frame draws the i-th frame of the video.
+func (v *Video) Frame(i int) *image.Paletted {
+	frame := newFrame(v.Width, v.Height, v.scroll.palette)
+	v.drawFrame(frame, i)
+	return frame
+}
+
+// drawFrame draws the i-th frame into frame, which must be blank.
+func (v *Video) drawFrame(frame *image.Paletted, i int) {
+	s := v.scroll
+	n := len(s.columns)
+
+	t := float64(i) / float64(v.FrameCount) * v.starts[n]
+
+	// find the scroll position shown at time t
+	j := sort.SearchFloat64s(v.starts, t)
+	if j >= n || v.starts[j] > t {
+		j--
+	}
+
+	size := s.dots.Size()
+	x := s.columns[j] * size
+
+	// move by pixels towards the next position
+	if v.smooth && s.moves[j] > 0 {
+		progress := (t - v.starts[j] - s.pauses[j]) / s.moves[j]
+		progress = math.Max(0, math.Min(1, progress))
+
+		step := s.columns[(j+1)%n] - s.columns[j]
+		if s.loopColumns > 0 {
+			if step > 1 {
+				step -= s.loopColumns
+			} else if step < -1 {
+				step += s.loopColumns
+			}
+		}
+
+		x += int(math.Round(progress * float64(step*size)))
+		if x < 0 && s.loopColumns > 0 {
+			x += s.loopColumns * size
+		}
+	}
+
+	s.drawWindow(frame, x)
+}
+
+// WriteRGB writes all frames of the video to w as raw 24-bit RGB pixels,
+// repeating the whole sequence the given number of times.
+// It stops with the context error when ctx is cancelled.
+func (v *Video) WriteRGB(ctx context.Context, w io.Writer, loops int) error {
+	colors := v.scroll.palette
+	rgb := make([][3]byte, len(colors))
+	for i, c := range colors {
+		r, g, b, _ := c.RGBA()
+		rgb[i] = [3]byte{byte(r >> 8), byte(g >> 8), byte(b >> 8)}
+	}
+
+	buf := make([]byte, v.Width*v.Height*3)
+
+	// a single frame is drawn over and over
+	frame := image.NewPaletted(image.Rect(0, 0, v.Width, v.Height), colors)
+
+	for range max(1, loops) {
+		for i := 0; i < v.FrameCount; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			clear(frame.Pix)
+			v.drawFrame(frame, i)
+
+			for y := 0; y < v.Height; y++ {
+				row := frame.Pix[y*frame.Stride : y*frame.Stride+v.Width]
+				for x, index := range row {
+					copy(buf[(y*v.Width+x)*3:], rgb[index][:])
+				}
+			}
+
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
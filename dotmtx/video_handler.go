@@ -0,0 +1,201 @@
+package dotmtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"petbots.fbbdev.it/dotmtxbot/log"
+)
+
+// VideoHandler serves animations as videos encoded with the profile
+// named by the profile parameter or, by default, with the first profile
+// matching the extension of the URL. When no profile is available or
+// the conversion fails, the animation is served as a GIF instead.
+func VideoHandler(w http.ResponseWriter, r *http.Request) {
+	if !allowRead(w, r) {
+		return
+	}
+
+	name := r.URL.Query().Get("profile")
+	profile, ok := selectProfile(name, r.URL.Path)
+	if !ok && name != "" && VideoAvailable() {
+		http.Error(w, "Unknown video profile", http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+
+	// invalid parameters get an animation explaining what is wrong
+	opts, err := ParseOptions(r.URL.Query())
+	if err != nil {
+		opts, status = ErrorOptions(err, opts), http.StatusBadRequest
+	}
+
+	ctx, cancel := renderContext(r)
+	defer cancel()
+
+	// do not even try when ffmpeg is known not to work
+	if !ok || !VideoAvailable() {
+		serveGifFallback(ctx, w, r, opts, status)
+		return
+	}
+
+	if checkCache(w, r, profile.key(), opts, status) {
+		return
+	}
+
+	// log.InfoLogger.Print("parameters are valid")
+
+	video, err := MakeVideo(opts)
+	if err != nil {
+		log.ErrorLogger.Print("MakeVideo: ", err)
+		log.WarningLogger.Print("video generation failed")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tmpDir, err := ioutil.TempDir("", "dotmtxbot_video")
+	if err != nil {
+		log.ErrorLogger.Print("ioutil: ", err)
+		log.WarningLogger.Print("temporary dir creation failed")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	videoPath := filepath.Join(tmpDir, "dotmtx."+profile.Extension)
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+	}
+
+	var stdin io.Reader
+
+	if video != nil {
+		// stream raw frames at a constant frame rate
+		args = append(args,
+			"-f", "rawvideo",
+			"-pix_fmt", "rgb24",
+			"-video_size", fmt.Sprintf("%dx%d", video.Width, video.Height),
+			"-framerate", strconv.Itoa(video.FPS),
+			"-i", "pipe:0",
+		)
+
+		pipeReader, pipeWriter := io.Pipe()
+		defer pipeReader.Close()
+
+		go func() {
+			pipeWriter.CloseWithError(video.WriteRGB(ctx, pipeWriter, opts.Loops))
+		}()
+
+		stdin = pipeReader
+	} else {
+		anim, err := MakeAnimation(ctx, opts)
+		if renderCancelled(w, r, err) {
+			return
+		} else if err != nil {
+			log.ErrorLogger.Print("MakeAnimation: ", err)
+			log.WarningLogger.Print("gif generation failed")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		gifPath := filepath.Join(tmpDir, "dotmtx.gif")
+
+		gifFile, err := os.Create(gifPath)
+		if err != nil {
+			log.ErrorLogger.Print("os: ", err)
+			log.WarningLogger.Print("temporary file creation failed")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		err = anim.EncodeGif(ctx, gifFile)
+		gifFile.Close()
+		if renderCancelled(w, r, err) {
+			return
+		} else if err != nil {
+			log.ErrorLogger.Print("gif: ", err)
+			log.WarningLogger.Print("could not write GIF to temporary file")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		// the gif demuxer plays the input once, repeat it for finite loop counts
+		if opts.Loops > 1 {
+			args = append(args, "-stream_loop", strconv.Itoa(opts.Loops-1))
+		}
+
+		args = append(args, "-i", gifPath)
+	}
+
+	var filters []string
+	if profile.Scale > 1 {
+		filters = append(filters, fmt.Sprintf("scale=iw*%d:ih*%d:flags=neighbor", profile.Scale, profile.Scale))
+	}
+
+	// chroma subsampled formats like yuv420p need even dimensions;
+	// the padding is black like the background of the display
+	filters = append(filters, "pad=ceil(iw/2)*2:ceil(ih/2)*2")
+
+	args = append(args, "-vf", strings.Join(filters, ","))
+
+	args = append(args, profile.Args...)
+	args = append(args, videoPath)
+
+	err = runFFmpeg(ctx, args, stdin)
+
+	var ffErr *FFmpegError
+	if renderCancelled(w, r, err) {
+		return
+	} else if errors.As(err, &ffErr) {
+		log.ErrorLogger.Printf("ffmpeg: %v (stderr=%q)", ffErr, ffErr.Stderr)
+		if renderCancelled(w, r, ctx.Err()) {
+			// the render deadline expired, there is no time left for a GIF
+			return
+		}
+		log.WarningLogger.Printf("video conversion failed, falling back to GIF (profile=%v, failure=%v, url=%v)", profile.Name, ffErr.Failure, r.URL)
+		serveGifFallback(ctx, w, r, opts, status)
+		return
+	} else if err != nil {
+		log.ErrorLogger.Print("exec: ", err)
+		log.WarningLogger.Printf("video conversion failed, falling back to GIF (profile=%v, url=%v)", profile.Name, r.URL)
+		serveGifFallback(ctx, w, r, opts, status)
+		return
+	}
+
+	videoFile, err := os.Open(videoPath)
+	if err != nil {
+		log.ErrorLogger.Print("os: ", err)
+		log.WarningLogger.Print("could not open converted video")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer videoFile.Close()
+
+	// no Last-Modified: the temporary file is new at every request
+	w.Header().Set("Content-Type", profile.ContentType)
+	if err := serveRender(w, r, status, videoFile); err != nil {
+		log.ErrorLogger.Print("http: ", err)
+		log.WarningLogger.Print("could not write http response")
+	}
+}
+
+// serveGifFallback serves the animation as a GIF when it cannot be
+// converted to video, so that clients still get something to show.
+// The response must not be cached, as it does not match the URL.
+func serveGifFallback(ctx context.Context, w http.ResponseWriter, r *http.Request, opts Options, status int) {
+	w.Header().Del("ETag")
+	w.Header().Set("Cache-Control", "no-store")
+
+	serveGif(ctx, w, r, opts, status)
+}
@@ -0,0 +1,107 @@
+// Package filecache remembers the file ids Telegram assigns to rendered
+// animations, so that they can be sent again without being refetched.
+package filecache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"petbots.fbbdev.it/dotmtxbot/persist"
+)
+
+type entry struct {
+	FileID   string    `json:"file_id"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// Cache maps digests of render parameters to Telegram file ids and persists
+// the mapping to a JSON file. Entries expire when unused for longer
+// than the given time to live. It is safe for concurrent use.
+type Cache struct {
+	path string
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]entry
+	dirty   bool
+}
+
+// Open returns a Cache persisted at path, loading
+// the entries saved there if any.
+func Open(path string, ttl time.Duration) (*Cache, error) {
+	c := &Cache{path: path, ttl: ttl, entries: map[string]entry{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err == nil {
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Get returns the file id stored for key and renews its lifetime.
+func (c *Cache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.LastUsed) > c.ttl {
+		return "", false
+	}
+
+	e.LastUsed = time.Now()
+	c.entries[key] = e
+	c.dirty = true
+
+	return e.FileID, true
+}
+
+// Put stores the file id for key.
+func (c *Cache) Put(key string, fileID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = entry{fileID, time.Now()}
+	c.dirty = true
+}
+
+// Delete forgets the file id for key, for example
+// when Telegram does not accept it anymore.
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.dirty = true
+	}
+}
+
+// Save drops expired entries and writes the others to disk
+// if anything changed since the last save.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	for key, e := range c.entries {
+		if time.Since(e.LastUsed) > c.ttl {
+			delete(c.entries, key)
+			c.dirty = true
+		}
+	}
+	c.mu.Unlock()
+
+	return persist.Save(&c.mu, &c.dirty, c.path, func() ([]byte, error) {
+		return json.Marshal(c.entries)
+	})
+}
+
+// AutoSave saves the cache at the given interval, forever.
+func (c *Cache) AutoSave(interval time.Duration) {
+	persist.AutoSave(interval, c.Save, "filecache", "file id cache")
+}
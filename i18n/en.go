@@ -0,0 +1,142 @@
+package i18n
+
+var english = map[Message]string{
+	Help: `Invoke me inline in any chat:
+@dotmtxbot [Speed] [Width] [Blank] [Text]
+
+Or in this chat:
+/render [Speed] [Width] [Blank] [Text]
+
+New here? Send /new and I will guide you step by step.
+
+[Speed] is the number of characters scrolling out of the display in one second, like 4 or 4cps. You can also give it in dots per second, like 30dps, as the duration of one full loop, like 3s, or as one of slow, normal and fast. Use a negative value to reverse the scrolling direction.
+
+[Width] is a number specifying the image width multiplier: when Width is 1, the image has the same width as the text. When 0.5, half the text. When 2, twice the text. You can also give an absolute width in dots, like 64d, or in pixels, like 200px.
+
+[Blank] is a number specifying the blank space multiplier: when Blank is 1, the text is followed by a blank space of the same width. When 0.5, half the width and so on. Like [Width], it can be given in dots or pixels.
+
+[Text] is the text to display. Maximum length is %d characters.
+
+Between [Blank] and [Text] you can add some options in the form name=value, like bounce=true or color=red. Send /options to see them all.
+
+Try invoking me inline in this chat! Go to the chatbar and write:
+@dotmtxbot 4 1 1 HELLO %s
+
+When everything works, a GIF will pop up which you can post.
+When the parameters are wrong, nothing will pop up.
+When the generated GIF is too big, I will try to make it fit with smaller dots or less blank space and tell you what I changed. If nothing works, I will send a GIF with an error message.
+
+You can also try sending me this message:
+/render 4 1 1 HELLO %s
+
+I will reply with a GIF or a message explaining what went wrong. Use the buttons under the GIF to change its speed, direction, width and color.
+
+To get a file you can save and reuse elsewhere, use /download with the same parameters, like:
+/download 4 1 1 format=gif HELLO %s
+
+To render a message that is already in the chat, reply to it with /render [Speed] [Width] [Blank], plus any options. Bold, italic, underline, strikethrough and code formatting are kept.
+
+In groups, address your commands to me, like /render@dotmtxbot, so that other bots are not bothered.
+
+PRIVACY NOTICE: your requests will never be stored nor traced back to you in any way by the bot. The bot only keeps anonymous counts of how many animations are made and which options they use. However, remember that this is a completely public service and you should never send private or personal data to this bot.
+The GIFs will be cached by a CDN to speed up delivery. Cached GIFs are only accessible by someone who knows the exact text they contain down to the smallest detail, so if they contain private data they should only be accessible by you. Let us stress again, however, that you should NEVER send private data to this bot. Our CDN, Cloudflare, may of course be able to access the GIFs that are stored in their caches, when required by the law. Here is their privacy policy:
+
+https://www.cloudflare.com/trust-hub/privacy-and-data-protection/`,
+
+	Options: `Between [Blank] and [Text] you can add some options in the form name=value:
+dwell=[Seconds] pauses the animation when the text is fully visible (at most %d seconds).
+bounce=true scrolls the text back and forth instead of looping it.
+ease=in|out|inout makes the scrolling accelerate or decelerate.
+loops=[Count] plays the animation a fixed number of times (at most %d).
+phase=[Offset] starts the animation at the given fraction of a loop, from 0 to 1.
+panel=[Columns]x[Rows] emulates a display with a fixed size in dots, like 96x16 or 128x32 (at most %d rows).
+align=left|center|right aligns static text on a panel; long text is wrapped on several rows.
+overflow=page|scroll shows static text that does not fit a panel in pages or scrolls it.
+fps=30|60 renders the video at a constant frame rate, so that fast scrolling does not stutter.
+smooth=true, together with fps, scrolls the text by pixels instead of whole dots.
+color=amber|red|green|blue|white sets the color of the display.
+style=[From]-[To][biusc] formats the characters from [From] up to [To] excluded, counted from 0, as bold, italic, underline, strikethrough or code, like 0-5b,6-9iu.
+format=mp4|gif|webm picks the file format; inline, webm is sent as mp4.
+
+For example:
+@dotmtxbot 4 1 1 bounce=true dwell=1 HELLO %s`,
+
+	RenderUsage:    "Some parameters are missing:\n/render [Speed] [Width] [Blank] [Text]\n\nOr reply to a message with:\n/render [Speed] [Width] [Blank]\n\nJust ask if you need some /help",
+	DownloadUsage:  "Some parameters are missing:\n/download [Speed] [Width] [Blank] [Text]\n\nOr reply to a message with:\n/download [Speed] [Width] [Blank]\n\nJust ask if you need some /help",
+	UnknownCommand: "I don't know that command",
+	Haha:           "LOL haha classic",
+
+	HelpCommand:     "How to use the bot",
+	OptionsCommand:  "Options to customize an animation",
+	RenderCommand:   "Render an animation in this chat",
+	DownloadCommand: "Render an animation as a file to download",
+	NewCommand:      "Create an animation step by step",
+	CancelCommand:   "Stop creating an animation",
+
+	NotEnoughParams: "Some parameters are missing! I need [Speed] [Width] [Blank] [Text]. Try asking for /help if you don't know how to invoke me.",
+	InvalidParams:   "Some parameters are not valid. [Speed], [Width] and [Blank] must be numbers. [Width] must be greater than zero and [Blank] must not be negative.",
+	InvalidSpeed:    "[Speed] is not valid. Use a number of characters per second like 4 or 4cps, a number of dots per second like 30dps, the duration of one loop like 3s (it must be longer than the pauses added by dwell) or one of slow, normal and fast. Negative values reverse the scrolling direction.",
+	InvalidWidth:    "[Width] is not valid. Use a multiplier of the text width greater than zero like 1 or 0.5, a width in dots like 64d or a width in pixels like 200px.",
+	InvalidBlank:    "[Blank] is not valid. Use a multiplier of the text width like 1 or 0.5, a width in dots like 16d or a width in pixels like 100px. It must not be negative.",
+	InvalidOption:   "Some options are not valid. Options go between [Blank] and [Text] and take the form name=value. Valid options are dwell, bounce, ease, loops, phase, panel, align, overflow, fps, smooth, color, style and format. Send /options if you don't know how to use them.",
+	TextTooLong:     "[Text] is too long. The limit is %d characters.",
+	UnsupportedChar: "[Text] contains a character I cannot display: %c",
+	TextRefused:     "Sorry, I can't display this text.",
+	InternalError:   "Something went wrong on my side. Please try again later.",
+
+	Adjusted:        "The animation was too big, so I made it fit with %s.",
+	AdjustedDots:    "smaller dots",
+	AdjustedPadding: "no padding",
+	AdjustedWidth:   "narrower display",
+	AdjustedBlank:   "less blank space",
+
+	SlowerButton:   "🐢 Slower",
+	FasterButton:   "🐇 Faster",
+	ReverseButton:  "⇄ Reverse",
+	NarrowerButton: "↔ Narrower",
+	WiderButton:    "⟷ Wider",
+	ColorButton:    "🎨 Color",
+	TweakExpired:   "This animation can no longer be changed, send /render again.",
+	TweakLimit:     "Can't go any further.",
+	TweakNotOwner:  "Only the person who asked for this animation can change it.",
+
+	WizardText:      "Let's make a new animation! What text should I display?\n\nSend /cancel to stop at any time.",
+	WizardSpeed:     "How fast should the text scroll? Pick a choice or write a speed like 4cps or 3s.",
+	WizardWidth:     "How wide should the display be? Pick a choice or write a width like 64d or 200px.",
+	WizardBlank:     "How much blank space should follow the text? Pick a choice or write an amount like 16d.",
+	WizardColor:     "Which color should the display have?",
+	WizardDone:      "Here is your animation! Send /new to make another one.",
+	WizardCancelled: "Okay, no animation this time.",
+	WizardPrivate:   "Send me /new in a private chat and I will guide you step by step.",
+	SpeedSlow:       "Slow",
+	SpeedNormal:     "Normal",
+	SpeedFast:       "Fast",
+	WidthHalf:       "Half the text",
+	WidthText:       "Like the text",
+	WidthDouble:     "Twice the text",
+	BlankNone:       "None",
+	BlankHalf:       "Half the text",
+	BlankFull:       "Like the text",
+	ColorAmber:      "Amber",
+	ColorRed:        "Red",
+	ColorGreen:      "Green",
+	ColorBlue:       "Blue",
+	ColorWhite:      "White",
+
+	StatsRenders: "Renders: %d total, %d today, %d in the last %d days",
+	StatsSources: "Inline: %d (%.0f%%), command: %d (%.0f%%)",
+
+	BlockUsage:      "Usage: /%s [User ID], or reply to a message of the user",
+	BlockAdmin:      "Admins cannot be blocked",
+	BlockSaveFailed: "Could not save the blocklist, the change will be lost on restart",
+	UserBlocked:     "User %d is now blocked",
+	UserUnblocked:   "User %d is no longer blocked",
+
+	ReasonTooBig:          "TOO BIG! REDUCE WIDTH, BLANK OR TEXT",
+	ReasonRefused:         "TEXT NOT ALLOWED",
+	ReasonTooLong:         "TEXT TOO LONG (max %d)",
+	ReasonUnsupportedChar: "UNSUPPORTED CHAR: %U",
+	ReasonUnknownOption:   "UNKNOWN OPTION: %s",
+	ReasonInvalidOption:   "BAD %s",
+	ReasonError:           "ERROR",
+}
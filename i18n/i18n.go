@@ -0,0 +1,167 @@
+// Package i18n holds the translations of the messages sent by the bot.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Message identifies a translatable message.
+type Message int
+
+const (
+	Help Message = iota
+	Options
+	RenderUsage
+	DownloadUsage
+	UnknownCommand
+	Haha
+
+	// command descriptions for the command menu
+	HelpCommand
+	OptionsCommand
+	RenderCommand
+	DownloadCommand
+	NewCommand
+	CancelCommand
+
+	// user errors
+	NotEnoughParams
+	InvalidParams
+	InvalidSpeed
+	InvalidWidth
+	InvalidBlank
+	InvalidOption
+	TextTooLong
+	UnsupportedChar
+	TextRefused
+	InternalError
+
+	// notes about adjustments made to fit an animation
+	Adjusted
+	AdjustedDots
+	AdjustedPadding
+	AdjustedWidth
+	AdjustedBlank
+
+	// buttons and notices of the tweaking keyboard
+	SlowerButton
+	FasterButton
+	ReverseButton
+	NarrowerButton
+	WiderButton
+	ColorButton
+	TweakExpired
+	TweakLimit
+	TweakNotOwner
+
+	// questions and choices of the /new wizard
+	WizardText
+	WizardSpeed
+	WizardWidth
+	WizardBlank
+	WizardColor
+	WizardDone
+	WizardCancelled
+	WizardPrivate
+	SpeedSlow
+	SpeedNormal
+	SpeedFast
+	WidthHalf
+	WidthText
+	WidthDouble
+	BlankNone
+	BlankHalf
+	BlankFull
+	ColorAmber
+	ColorRed
+	ColorGreen
+	ColorBlue
+	ColorWhite
+
+	// summary sent by the /stats admin command
+	StatsRenders
+	StatsSources
+
+	// replies to the /block and /unblock admin commands
+	BlockUsage
+	BlockAdmin
+	BlockSaveFailed
+	UserBlocked
+	UserUnblocked
+
+	// reasons shown by error animations, in capitals
+	// and with characters the dot matrix font can display
+	ReasonTooBig
+	ReasonRefused
+	ReasonTooLong
+	ReasonUnsupportedChar
+	ReasonUnknownOption
+	ReasonInvalidOption
+	ReasonError
+)
+
+// DefaultLanguage is used when the language of the user
+// is unknown or not supported.
+const DefaultLanguage = "en"
+
+var catalogs = map[string]map[Message]string{
+	"en": english,
+	"it": italian,
+}
+
+// Languages returns the codes of all supported languages.
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	return langs
+}
+
+// Lang maps an IETF language tag, as reported by Telegram,
+// to a supported language, falling back to DefaultLanguage.
+func Lang(code string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(code), "-")
+	if _, ok := catalogs[lang]; ok {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// Text returns the given message translated into lang; when args are
+// present, the message is used as a format string. Messages missing
+// from a catalog are taken from the default language.
+func Text(lang string, msg Message, args ...any) string {
+	text, ok := catalogs[Lang(lang)][msg]
+	if !ok {
+		text = catalogs[DefaultLanguage][msg]
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+
+	return text
+}
+
+// UserError is an error meant to be shown to the user.
+// Its Error method returns the message in the default language.
+type UserError struct {
+	Message Message
+	Args    []any
+}
+
+// NewError returns a UserError for the given message and arguments.
+func NewError(msg Message, args ...any) *UserError {
+	return &UserError{msg, args}
+}
+
+func (e *UserError) Error() string {
+	return e.Localize(DefaultLanguage)
+}
+
+// Localize returns the error message translated into lang.
+func (e *UserError) Localize(lang string) string {
+	return Text(lang, e.Message, e.Args...)
+}
@@ -0,0 +1,142 @@
+package i18n
+
+var italian = map[Message]string{
+	Help: `Invocami inline in qualsiasi chat:
+@dotmtxbot [Velocità] [Larghezza] [Spazio] [Testo]
+
+Oppure in questa chat:
+/render [Velocità] [Larghezza] [Spazio] [Testo]
+
+Sei nuovo? Invia /new e ti guiderò passo passo.
+
+[Velocità] è il numero di caratteri che escono dal display in un secondo, come 4 o 4cps. Puoi anche indicarla in punti al secondo, come 30dps, come durata di un giro completo, come 3s, oppure con una tra slow, normal e fast. Usa un valore negativo per invertire la direzione di scorrimento.
+
+[Larghezza] è un numero che indica il moltiplicatore della larghezza dell'immagine: quando vale 1, l'immagine è larga quanto il testo. Quando vale 0.5, metà del testo. Quando vale 2, il doppio del testo. Puoi anche indicare una larghezza assoluta in punti, come 64d, o in pixel, come 200px.
+
+[Spazio] è un numero che indica il moltiplicatore dello spazio vuoto: quando vale 1, il testo è seguito da uno spazio vuoto della stessa larghezza. Quando vale 0.5, metà della larghezza e così via. Come [Larghezza], può essere indicato in punti o in pixel.
+
+[Testo] è il testo da mostrare. La lunghezza massima è di %d caratteri.
+
+Tra [Spazio] e [Testo] puoi aggiungere alcune opzioni nella forma nome=valore, come bounce=true o color=red. Invia /options per vederle tutte.
+
+Prova a invocarmi inline in questa chat! Vai nella barra della chat e scrivi:
+@dotmtxbot 4 1 1 CIAO %s
+
+Se è tutto a posto, comparirà una GIF che potrai inviare.
+Se i parametri sono sbagliati, non comparirà nulla.
+Se la GIF generata è troppo grande, proverò a farla stare nei limiti con punti più piccoli o meno spazio vuoto e ti dirò cosa ho cambiato. Se non funziona nulla, invierò una GIF con un messaggio di errore.
+
+Puoi anche provare a mandarmi questo messaggio:
+/render 4 1 1 CIAO %s
+
+Risponderò con una GIF o con un messaggio che spiega cosa è andato storto. Usa i pulsanti sotto la GIF per cambiarne velocità, direzione, larghezza e colore.
+
+Per ottenere un file da salvare e riusare altrove, usa /download con gli stessi parametri, come:
+/download 4 1 1 format=gif CIAO %s
+
+Per animare un messaggio già presente nella chat, rispondigli con /render [Velocità] [Larghezza] [Spazio], più eventuali opzioni. La formattazione in grassetto, corsivo, sottolineato, barrato e codice viene mantenuta.
+
+Nei gruppi, indirizza i comandi a me, come /render@dotmtxbot, così gli altri bot non vengono disturbati.
+
+INFORMATIVA SULLA PRIVACY: le tue richieste non verranno mai memorizzate né ricondotte a te in alcun modo dal bot. Il bot tiene solo conteggi anonimi di quante animazioni vengono create e di quali opzioni usano. Ricorda però che questo è un servizio completamente pubblico e non dovresti mai inviare dati privati o personali a questo bot.
+Le GIF verranno memorizzate nella cache di una CDN per velocizzarne la consegna. Le GIF in cache sono accessibili solo a chi conosce esattamente il testo che contengono fin nel minimo dettaglio, quindi se contengono dati privati dovrebbero essere accessibili solo a te. Ribadiamo comunque che NON dovresti MAI inviare dati privati a questo bot. La nostra CDN, Cloudflare, potrebbe ovviamente accedere alle GIF memorizzate nelle sue cache, quando richiesto dalla legge. Ecco la sua informativa sulla privacy:
+
+https://www.cloudflare.com/trust-hub/privacy-and-data-protection/`,
+
+	Options: `Tra [Spazio] e [Testo] puoi aggiungere alcune opzioni nella forma nome=valore:
+dwell=[Secondi] ferma l'animazione quando il testo è completamente visibile (al massimo %d secondi).
+bounce=true fa scorrere il testo avanti e indietro invece di ripeterlo.
+ease=in|out|inout fa accelerare o rallentare lo scorrimento.
+loops=[Numero] riproduce l'animazione un numero fisso di volte (al massimo %d).
+phase=[Frazione] fa partire l'animazione dalla frazione di giro indicata, da 0 a 1.
+panel=[Colonne]x[Righe] emula un display di dimensioni fisse in punti, come 96x16 o 128x32 (al massimo %d righe).
+align=left|center|right allinea il testo statico su un pannello; il testo lungo va a capo su più righe.
+overflow=page|scroll mostra il testo statico che non entra in un pannello a pagine o facendolo scorrere.
+fps=30|60 genera il video a frequenza costante, così lo scorrimento veloce non scatta.
+smooth=true, insieme a fps, fa scorrere il testo di un pixel alla volta invece che di un punto.
+color=amber|red|green|blue|white imposta il colore del display.
+style=[Da]-[A][biusc] formatta i caratteri da [Da] ad [A] escluso, contati da 0, in grassetto, corsivo, sottolineato, barrato o codice, come 0-5b,6-9iu.
+format=mp4|gif|webm sceglie il formato del file; inline, webm viene inviato come mp4.
+
+Per esempio:
+@dotmtxbot 4 1 1 bounce=true dwell=1 CIAO %s`,
+
+	RenderUsage:    "Mancano alcuni parametri:\n/render [Velocità] [Larghezza] [Spazio] [Testo]\n\nOppure rispondi a un messaggio con:\n/render [Velocità] [Larghezza] [Spazio]\n\nChiedi pure se ti serve /help",
+	DownloadUsage:  "Mancano alcuni parametri:\n/download [Velocità] [Larghezza] [Spazio] [Testo]\n\nOppure rispondi a un messaggio con:\n/download [Velocità] [Larghezza] [Spazio]\n\nChiedi pure se ti serve /help",
+	UnknownCommand: "Non conosco questo comando",
+	Haha:           "LOL haha classico",
+
+	HelpCommand:     "Come usare il bot",
+	OptionsCommand:  "Opzioni per personalizzare un'animazione",
+	RenderCommand:   "Genera un'animazione in questa chat",
+	DownloadCommand: "Genera un'animazione come file da scaricare",
+	NewCommand:      "Crea un'animazione passo passo",
+	CancelCommand:   "Interrompi la creazione di un'animazione",
+
+	NotEnoughParams: "Mancano alcuni parametri! Mi servono [Velocità] [Larghezza] [Spazio] [Testo]. Chiedi /help se non sai come invocarmi.",
+	InvalidParams:   "Alcuni parametri non sono validi. [Velocità], [Larghezza] e [Spazio] devono essere numeri. [Larghezza] deve essere maggiore di zero e [Spazio] non deve essere negativo.",
+	InvalidSpeed:    "[Velocità] non è valida. Usa un numero di caratteri al secondo come 4 o 4cps, un numero di punti al secondo come 30dps, la durata di un giro come 3s (deve superare le pause aggiunte da dwell) oppure una tra slow, normal e fast. I valori negativi invertono la direzione di scorrimento.",
+	InvalidWidth:    "[Larghezza] non è valida. Usa un moltiplicatore della larghezza del testo maggiore di zero come 1 o 0.5, una larghezza in punti come 64d o una larghezza in pixel come 200px.",
+	InvalidBlank:    "[Spazio] non è valido. Usa un moltiplicatore della larghezza del testo come 1 o 0.5, una larghezza in punti come 16d o una larghezza in pixel come 100px. Non deve essere negativo.",
+	InvalidOption:   "Alcune opzioni non sono valide. Le opzioni vanno tra [Spazio] e [Testo] nella forma nome=valore. Le opzioni valide sono dwell, bounce, ease, loops, phase, panel, align, overflow, fps, smooth, color, style e format. Invia /options se non sai come usarle.",
+	TextTooLong:     "[Testo] è troppo lungo. Il limite è di %d caratteri.",
+	UnsupportedChar: "[Testo] contiene un carattere che non so mostrare: %c",
+	TextRefused:     "Mi dispiace, non posso mostrare questo testo.",
+	InternalError:   "Qualcosa è andato storto da parte mia. Riprova più tardi.",
+
+	Adjusted:        "L'animazione era troppo grande, quindi l'ho fatta stare nei limiti con %s.",
+	AdjustedDots:    "punti più piccoli",
+	AdjustedPadding: "nessuno spazio tra i punti",
+	AdjustedWidth:   "display più stretto",
+	AdjustedBlank:   "meno spazio vuoto",
+
+	SlowerButton:   "🐢 Più lento",
+	FasterButton:   "🐇 Più veloce",
+	ReverseButton:  "⇄ Inverti",
+	NarrowerButton: "↔ Più stretto",
+	WiderButton:    "⟷ Più largo",
+	ColorButton:    "🎨 Colore",
+	TweakExpired:   "Questa animazione non può più essere modificata, invia di nuovo /render.",
+	TweakLimit:     "Non si può andare oltre.",
+	TweakNotOwner:  "Solo chi ha chiesto questa animazione può modificarla.",
+
+	WizardText:      "Creiamo una nuova animazione! Che testo devo mostrare?\n\nInvia /cancel per interrompere in qualsiasi momento.",
+	WizardSpeed:     "Quanto velocemente deve scorrere il testo? Scegli un'opzione o scrivi una velocità come 4cps o 3s.",
+	WizardWidth:     "Quanto deve essere largo il display? Scegli un'opzione o scrivi una larghezza come 64d o 200px.",
+	WizardBlank:     "Quanto spazio vuoto deve seguire il testo? Scegli un'opzione o scrivi una quantità come 16d.",
+	WizardColor:     "Di che colore deve essere il display?",
+	WizardDone:      "Ecco la tua animazione! Invia /new per crearne un'altra.",
+	WizardCancelled: "Va bene, niente animazione per questa volta.",
+	WizardPrivate:   "Inviami /new in una chat privata e ti guiderò passo passo.",
+	SpeedSlow:       "Lenta",
+	SpeedNormal:     "Normale",
+	SpeedFast:       "Veloce",
+	WidthHalf:       "Metà del testo",
+	WidthText:       "Come il testo",
+	WidthDouble:     "Il doppio del testo",
+	BlankNone:       "Nessuno",
+	BlankHalf:       "Metà del testo",
+	BlankFull:       "Come il testo",
+	ColorAmber:      "Ambra",
+	ColorRed:        "Rosso",
+	ColorGreen:      "Verde",
+	ColorBlue:       "Blu",
+	ColorWhite:      "Bianco",
+
+	StatsRenders: "Animazioni: %d in totale, %d oggi, %d negli ultimi %d giorni",
+	StatsSources: "Inline: %d (%.0f%%), comando: %d (%.0f%%)",
+
+	BlockUsage:      "Uso: /%s [ID utente], oppure rispondi a un messaggio dell'utente",
+	BlockAdmin:      "Gli amministratori non possono essere bloccati",
+	BlockSaveFailed: "Non ho potuto salvare la lista dei blocchi, la modifica andrà persa al riavvio",
+	UserBlocked:     "L'utente %d ora è bloccato",
+	UserUnblocked:   "L'utente %d non è più bloccato",
+
+	ReasonTooBig:          "TROPPO GRANDE! RIDUCI LARGHEZZA, SPAZIO O TESTO",
+	ReasonRefused:         "TESTO NON CONSENTITO",
+	ReasonTooLong:         "TESTO TROPPO LUNGO (max %d)",
+	ReasonUnsupportedChar: "CARATTERE NON SUPPORTATO: %U",
+	ReasonUnknownOption:   "OPZIONE SCONOSCIUTA: %s",
+	ReasonInvalidOption:   "PARAMETRO NON VALIDO: %s",
+	ReasonError:           "ERRORE",
+}
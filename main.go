@@ -1,25 +1,70 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"image/png"
+	"io"
+	"math"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"petbots.fbbdev.it/dotmtxbot/dotmtx"
+	"petbots.fbbdev.it/dotmtxbot/filecache"
+	"petbots.fbbdev.it/dotmtxbot/i18n"
 	"petbots.fbbdev.it/dotmtxbot/log"
+	"petbots.fbbdev.it/dotmtxbot/moderation"
+	"petbots.fbbdev.it/dotmtxbot/stats"
 )
 
 var publicHost string
 var imgServiceAddr string
 var gifPath string
 var mp4Path string
+var webmPath string
+var thumbPath string
+var statsPath string
+var moderationRulesPath string
+var blocklistPath string
+var fileCachePath string
+var videoProfilesPath string
+var admins map[int64]bool
+
+// stateDirectory returns the directory where the bot keeps its state
+// by default: the one set up by systemd if any, otherwise the XDG state
+// directory of the user. It must lie outside the checkout, which is
+// replaced on every deploy.
+func stateDirectory() string {
+	if dir, _, _ := strings.Cut(os.Getenv("STATE_DIRECTORY"), ":"); dir != "" {
+		return dir
+	}
+
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "dotmtxbot")
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "dotmtxbot")
+	}
+
+	return "."
+}
 
 func init() {
 	publicHost = os.Getenv("DOTMTXBOT_PUBLIC_HOST")
@@ -38,59 +83,143 @@ func init() {
 	}
 
 	mp4Path = os.Getenv("DOTMTXBOT_MP4_PATH")
-	if gifPath == "" {
-		gifPath = "/dotmtx.mp4"
+	if mp4Path == "" {
+		mp4Path = "/dotmtx.mp4"
 	}
-}
 
-func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	if _, err := bot.Send(msg); err != nil {
-		log.ErrorLogger.Print("tgbotapi: ", err)
-		log.WarningLogger.Printf("could not send message (chat_id=%v)", chatID)
+	webmPath = os.Getenv("DOTMTXBOT_WEBM_PATH")
+	if webmPath == "" {
+		webmPath = "/dotmtx.webm"
+	}
+
+	thumbPath = os.Getenv("DOTMTXBOT_THUMB_PATH")
+	if thumbPath == "" {
+		thumbPath = "/dotmtx.jpg"
+	}
+
+	statsPath = os.Getenv("DOTMTXBOT_STATS_PATH")
+	if statsPath == "" {
+		statsPath = filepath.Join(stateDirectory(), "stats.json")
+	}
+
+	moderationRulesPath = os.Getenv("DOTMTXBOT_MODERATION_RULES")
+	if moderationRulesPath == "" {
+		moderationRulesPath = "config/moderation.conf"
+	}
+
+	blocklistPath = os.Getenv("DOTMTXBOT_BLOCKLIST")
+	if blocklistPath == "" {
+		blocklistPath = filepath.Join(stateDirectory(), "blocklist.txt")
+	}
+
+	fileCachePath = os.Getenv("DOTMTXBOT_FILE_CACHE")
+	if fileCachePath == "" {
+		fileCachePath = filepath.Join(stateDirectory(), "filecache.json")
+	}
+
+	videoProfilesPath = os.Getenv("DOTMTXBOT_VIDEO_PROFILES")
+	if videoProfilesPath == "" {
+		videoProfilesPath = "config/profiles.conf"
+	}
+
+	// comma separated list of user ids allowed to use admin commands
+	admins = map[int64]bool{}
+	for _, field := range strings.Split(os.Getenv("DOTMTXBOT_ADMINS"), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			log.WarningLogger.Printf("invalid admin user id %q", field)
+			continue
+		}
+
+		admins[id] = true
 	}
 }
 
-const helpMessage = `Invoke me inline in any chat:
-@dotmtxbot [Speed] [Width] [Blank] [Text]
+var recorder *stats.Recorder
+var moderator *moderation.Moderator
+var files *filecache.Cache
 
-Or in this chat:
-/render [Speed] [Width] [Blank] [Text]
+// fileCacheTTL is how long unused file ids are remembered
+const fileCacheTTL = 30 * 24 * time.Hour
 
-[Speed] is the number of characters scrolling out of the display in one second; use a negative value to reverse the scrolling direction.
+// isGroup reports whether chat is a group or supergroup.
+func isGroup(chat *tgbotapi.Chat) bool {
+	return chat.IsGroup() || chat.IsSuperGroup()
+}
 
-[Width] is a number specifying the image width multiplier: when Width is 1, the image has the same width as the text. When 0.5, half the text. When 2, twice the text.
+// threadReply makes a message sent in response to message
+// a reply to it when in groups, so that conversations stay threaded.
+func threadReply(config *tgbotapi.BaseChat, message *tgbotapi.Message) {
+	if isGroup(message.Chat) {
+		config.ReplyToMessageID = message.MessageID
+	}
+}
 
-[Blank] is a number specifying the blank space multiplier: when Blank is 1, the text is followed by a blank space of the same width. When 0.5, half the width and so on.
+// sendMessage sends text to the chat where message was posted.
+func sendMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, text string) {
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	threadReply(&msg.BaseChat, message)
 
-[Text] is the text to display. Maximum length is %d characters.
+	if _, err := bot.Send(msg); err != nil {
+		log.ErrorLogger.Print("tgbotapi: ", err)
+		log.WarningLogger.Printf("could not send message (chat_id=%v)", msg.ChatID)
+	}
+}
 
-Try invoking me inline in this chat! Go to the chatbar and write:
-@dotmtxbot 4 1 1 HELLO %s
+// addressedToBot reports whether a command is meant for this bot:
+// in groups, where several bots may be listening, commands
+// must be addressed explicitly like /render@dotmtxbot.
+func addressedToBot(bot *tgbotapi.BotAPI, message *tgbotapi.Message) bool {
+	if !isGroup(message.Chat) {
+		return true
+	}
 
-When everything works, a GIF will pop up which you can post.
-When the parameters are wrong, nothing will pop up.
-When the generated GIF is too big, I will send a GIF with an error message.
+	_, username, _ := strings.Cut(message.CommandWithAt(), "@")
+	return strings.EqualFold(username, bot.Self.UserName)
+}
 
-You can also try sending me this message:
-/render 4 1 1 HELLO %s
+// handleUnknownCommand tells the user the command does not exist;
+// it stays silent in groups to avoid noise.
+func handleUnknownCommand(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	if isGroup(update.Message.Chat) {
+		return
+	}
 
-I will reply with a GIF or a message explaining what went wrong.
+	sendMessage(bot, update.Message, i18n.Text(userLang(update), i18n.UnknownCommand))
+}
 
-PRIVACY NOTICE: your requests will never be stored nor traced back to you in any way by the bot. However, remember that this is a completely public service and you should never send private or personal data to this bot.
-The GIFs will be cached by a CDN to speed up delivery. Cached GIFs are only accessible by someone who knows the exact text they contain down to the smallest detail, so if they contain private data they should only be accessible by you. Let us stress again, however, that you should NEVER send private data to this bot. Our CDN, Cloudflare, may of course be able to access the GIFs that are stored in their caches, when required by the law. Here is their privacy policy:
+// userLang returns the language of the user who sent the update.
+func userLang(update tgbotapi.Update) string {
+	if user := update.SentFrom(); user != nil {
+		return i18n.Lang(user.LanguageCode)
+	}
+	return i18n.DefaultLanguage
+}
 
-https://www.cloudflare.com/trust-hub/privacy-and-data-protection/`
+// errorText returns the message to be shown to the user for err;
+// internal errors are replaced with a generic message.
+func errorText(err error, lang string) string {
+	var userErr *i18n.UserError
+	if errors.As(err, &userErr) {
+		return userErr.Localize(lang)
+	}
+	return i18n.Text(lang, i18n.InternalError)
+}
 
 func handleHelp(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
 	username := strings.ToUpper(update.SentFrom().UserName)
 
 	msg := tgbotapi.NewMessage(
 		update.Message.Chat.ID,
-		fmt.Sprintf(helpMessage, dotmtx.MaxChars, username, username),
+		i18n.Text(userLang(update), i18n.Help, dotmtx.MaxChars, username, username, username),
 	)
 
 	msg.DisableWebPagePreview = true
+	threadReply(&msg.BaseChat, update.Message)
 
 	if _, err := bot.Send(msg); err != nil {
 		log.ErrorLogger.Print("tgbotapi: ", err)
@@ -98,85 +227,563 @@ func handleHelp(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
 	}
 }
 
-//lint:ignore ST1005 the string must be sent as a chat message
-var errNotEnoughParams = errors.New("Some parameters are missing! I need [Speed] [Width] [Blank] [Text]. Try asking for /help if you don't know how to invoke me.")
+func handleOptions(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	username := strings.ToUpper(update.SentFrom().UserName)
+
+	msg := tgbotapi.NewMessage(
+		update.Message.Chat.ID,
+		i18n.Text(userLang(update), i18n.Options, dotmtx.MaxDwell, dotmtx.MaxLoops, dotmtx.MaxPanelRows, username),
+	)
+
+	msg.DisableWebPagePreview = true
+	threadReply(&msg.BaseChat, update.Message)
 
-//lint:ignore ST1005 the string must be sent as a chat message
-var errInvalidParams = errors.New("Some parameters are not valid. [Speed], [Width] and [Blank] must be numbers. [Width] must not be negative and [Blank] must be greater than zero.")
+	if _, err := bot.Send(msg); err != nil {
+		log.ErrorLogger.Print("tgbotapi: ", err)
+		log.WarningLogger.Printf("could not send options message (update_id=%v, chat_id=%v)", update.UpdateID, msg.ChatID)
+	}
+}
 
-//lint:ignore ST1005 the string must be sent as a chat message
-var errTextTooLong = fmt.Errorf("[Text] is too long. The limit is %v characters.", dotmtx.MaxChars)
+var errNotEnoughParams = i18n.NewError(i18n.NotEnoughParams)
+var errInvalidParams = i18n.NewError(i18n.InvalidParams)
+var errInvalidSpeed = i18n.NewError(i18n.InvalidSpeed)
+var errInvalidWidth = i18n.NewError(i18n.InvalidWidth)
+var errInvalidBlank = i18n.NewError(i18n.InvalidBlank)
+var errInvalidOption = i18n.NewError(i18n.InvalidOption)
+var errTextTooLong = i18n.NewError(i18n.TextTooLong, dotmtx.MaxChars)
 
-func queryToURL(query string) (imgURL string, err error) {
+func parseQuery(query string) (opts dotmtx.Options, err error) {
 	re := regexp.MustCompile(`^\s*(\S+\s+\S+\s+\S+)\s+(.+)$`)
 	match := re.FindStringSubmatch(query)
 
 	// log.InfoLogger.Print("query string matched: ", match)
 
 	if match == nil || match[2] == "" {
-		return "", errNotEnoughParams
+		return opts, errNotEnoughParams
 	}
 
-	var speed float64
-	var width float64
-	var blank float64
+	params := strings.Fields(match[1])
 
-	if _, ierr := fmt.Sscan(match[1], &speed, &width, &blank); ierr != nil || width <= 0 || blank < 0 {
-		return "", errInvalidParams
+	// options in the form name=value may precede the text
+	text := match[2]
+	optionRe := regexp.MustCompile(`^([a-z]+)=(\S*)\s+(.+)$`)
+
+	for {
+		option := optionRe.FindStringSubmatch(text)
+		if option == nil {
+			break
+		}
+
+		if oerr := opts.Set(option[1], option[2]); oerr != nil {
+			if errors.Is(oerr, dotmtx.ErrUnknownOption) {
+				// not an option, must be part of the text
+				break
+			}
+			return opts, errInvalidOption
+		}
+
+		text = option[3]
+	}
+
+	return finishQuery(opts, params, text)
+}
+
+// parseReplyQuery parses the arguments of a command sent in reply
+// to a message: [Speed] [Width] [Blank] followed by options only,
+// as the text and its styles come from the replied-to message.
+// When the arguments carry a text of their own, they are parsed
+// as a full query and the replied-to message is ignored.
+func parseReplyQuery(query string, text string, styles []dotmtx.StyleSpan) (opts dotmtx.Options, err error) {
+	fields := strings.Fields(query)
+	if len(fields) < 3 {
+		return opts, errNotEnoughParams
 	}
 
-	// log.InfoLogger.Print("speed=", speed, ", width=", width, ", blank=", blank)
+	for _, field := range fields[3:] {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return parseQuery(query)
+		}
 
-	text := match[2]
+		if oerr := opts.Set(name, value); oerr != nil {
+			if errors.Is(oerr, dotmtx.ErrUnknownOption) {
+				// not an option, must be part of the text
+				return parseQuery(query)
+			}
+			return opts, errInvalidOption
+		}
+	}
+
+	opts.Styles = append(opts.Styles, styles...)
+
+	return finishQuery(opts, fields[:3], text)
+}
+
+// finishQuery sets the text and the mandatory parameters
+// of a partially parsed query and validates the result.
+func finishQuery(opts dotmtx.Options, params []string, text string) (dotmtx.Options, error) {
 	if len(text) > dotmtx.MaxChars {
-		return "", errTextTooLong
+		return opts, errTextTooLong
 	}
 
-	params := url.Values{}
-	params.Set("speed", fmt.Sprint(speed))
-	params.Set("width", fmt.Sprint(width))
-	params.Set("blank", fmt.Sprint(blank))
-	params.Set("text", text)
+	opts.Text = text
 
+	if perr := opts.SetParams(params[0], params[1], params[2]); perr != nil {
+		switch perr {
+		case dotmtx.ErrInvalidSpeed:
+			return opts, errInvalidSpeed
+		case dotmtx.ErrInvalidWidth:
+			return opts, errInvalidWidth
+		case dotmtx.ErrInvalidBlank:
+			return opts, errInvalidBlank
+		default:
+			return opts, errInvalidParams
+		}
+	}
+
+	// log.InfoLogger.Print("speed=", opts.Speed, ", width=", opts.Width, ", blank=", opts.Blank)
+
+	if verr := opts.Validate(); verr != nil {
+		var charErr *dotmtx.UnsupportedCharError
+		var optionErr *dotmtx.OptionError
+		switch {
+		case verr == dotmtx.ErrTextRefused:
+			return opts, i18n.NewError(i18n.TextRefused)
+		case errors.As(verr, &charErr):
+			return opts, i18n.NewError(i18n.UnsupportedChar, charErr.Char)
+		case errors.As(verr, &optionErr) && optionErr.Name == "style":
+			return opts, errInvalidOption
+		}
+		return opts, errInvalidParams
+	}
+
+	return opts, nil
+}
+
+// entityStyles maps message entity types to dot matrix styles
+var entityStyles = map[string]dotmtx.Style{
+	"bold":          dotmtx.StyleBold,
+	"italic":        dotmtx.StyleItalic,
+	"underline":     dotmtx.StyleUnderline,
+	"strikethrough": dotmtx.StyleStrike,
+	"code":          dotmtx.StyleCode,
+	"pre":           dotmtx.StyleCode,
+}
+
+// messageText returns the text or caption of a message, with line breaks
+// and other whitespace turned into spaces, along with the styles
+// of its formatting entities.
+func messageText(message *tgbotapi.Message) (string, []dotmtx.StyleSpan) {
+	text, entities := message.Text, message.Entities
+	if text == "" {
+		text, entities = message.Caption, message.CaptionEntities
+	}
+
+	// entity offsets are counted in UTF-16 code units, styles in runes
+	var runeIndex []int
+	runes := []rune(text)
+	for i, r := range runes {
+		for range utf16.RuneLen(r) {
+			runeIndex = append(runeIndex, i)
+		}
+		if unicode.IsSpace(r) {
+			runes[i] = ' '
+		}
+	}
+	runeIndex = append(runeIndex, len(runes))
+
+	var styles []dotmtx.StyleSpan
+	for _, entity := range entities {
+		style, ok := entityStyles[entity.Type]
+		if !ok || entity.Offset < 0 || entity.Length <= 0 || entity.Offset+entity.Length >= len(runeIndex) {
+			continue
+		}
+
+		styles = append(styles, dotmtx.StyleSpan{
+			Start: runeIndex[entity.Offset],
+			End:   runeIndex[entity.Offset+entity.Length],
+			Style: style,
+		})
+	}
+
+	return string(runes), styles
+}
+
+// renderFormat returns the format the animation for opts is served in:
+// videos are replaced by GIFs when their format is unavailable.
+func renderFormat(opts dotmtx.Options) dotmtx.Format {
+	if !dotmtx.FormatAvailable(opts.Format) {
+		return dotmtx.FormatGIF
+	}
+	return opts.Format
+}
+
+// renderURL returns the URL of the animation for opts
+// in the format it is served in.
+// withErrorLang sets the language of the error animation sent when opts
+// cannot fit within resource limits; other animations do not depend
+// on the language, so that their URLs are the same for everybody.
+func withErrorLang(opts dotmtx.Options, lang string) dotmtx.Options {
+	if _, ok := dotmtx.Fit(opts); !ok {
+		opts.Lang = lang
+	}
+	return opts
+}
+
+func renderURL(opts dotmtx.Options) string {
+	switch renderFormat(opts) {
+	case dotmtx.FormatGIF:
+		return publicURL(gifPath, opts)
+	case dotmtx.FormatWebM:
+		return publicURL(webmPath, opts)
+	default:
+		return publicURL(mp4Path, opts)
+	}
+}
+
+// renderInfo returns the size of the animation for opts and its
+// duration in whole seconds, as reported to Telegram; they are zero
+// when unknown.
+func renderInfo(opts dotmtx.Options) (width, height, duration int) {
+	opts.Format = renderFormat(opts)
+
+	// inline queries arrive at every keystroke, do not hold them up
+	ctx, cancel := context.WithTimeout(context.Background(), renderInfoTimeout)
+	defer cancel()
+
+	info, err := dotmtx.Describe(ctx, opts)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.WarningLogger.Print("animation took too long to describe")
+		return 0, 0, 0
+	} else if err != nil {
+		log.ErrorLogger.Print("dotmtx: ", err)
+		log.WarningLogger.Print("could not describe animation")
+		return 0, 0, 0
+	}
+
+	return info.Width, info.Height, int(math.Ceil(info.Duration.Seconds()))
+}
+
+// thumbURL returns the URL of a small still image of the animation.
+func thumbURL(opts dotmtx.Options) string {
+	return publicURL(thumbPath, opts)
+}
+
+func publicURL(path string, opts dotmtx.Options) string {
 	imgURLInfo := url.URL{
 		Scheme:   "https",
 		Host:     publicHost,
-		Path:     mp4Path,
-		RawQuery: params.Encode(),
+		Path:     path,
+		RawQuery: opts.Values().Encode(),
+	}
+
+	return imgURLInfo.String()
+}
+
+// renderKey returns a digest of the render parameters, used to look up
+// file ids assigned by Telegram. The text must not be stored in clear,
+// as the cache is persisted.
+func renderKey(opts dotmtx.Options) string {
+	return fileKey("animation", opts)
+}
+
+// downloadKey is like renderKey, for animations sent as documents.
+func downloadKey(opts dotmtx.Options) string {
+	return fileKey("document", opts)
+}
+
+// fileKey returns the hex SHA-256 digest of the renderer version,
+// the kind of message, the format and the canonical parameters.
+func fileKey(kind string, opts dotmtx.Options) string {
+	opts.Format = renderFormat(opts)
+	sum := sha256.Sum256([]byte(dotmtx.RendererVersion + "\x00" + kind + "\x00" + opts.Format.String() + "\x00" + opts.Values().Encode()))
+	return hex.EncodeToString(sum[:])
+}
+
+// renderInfoTimeout bounds the time spent measuring an animation
+const renderInfoTimeout = 200 * time.Millisecond
+
+// maxUploadSize is the largest file bots can upload to Telegram
+const maxUploadSize = 50 << 20
+
+// renderClient fetches animations to be uploaded as documents
+var renderClient = &http.Client{Timeout: 2 * time.Minute}
+
+// fetchRender downloads the animation for opts from the image service,
+// as Telegram cannot send most formats as documents by URL.
+func fetchRender(opts dotmtx.Options) (tgbotapi.FileBytes, error) {
+	var file tgbotapi.FileBytes
+
+	resp, err := renderClient.Get(renderURL(opts))
+	if err != nil {
+		return file, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return file, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	// the service may fall back to another format than requested
+	file.Name = "dotmtx." + fileExtension(resp.Header.Get("Content-Type"))
+
+	file.Bytes, err = io.ReadAll(io.LimitReader(resp.Body, maxUploadSize+1))
+	if err == nil && len(file.Bytes) > maxUploadSize {
+		err = fmt.Errorf("file larger than %d bytes", maxUploadSize)
+	}
+
+	return file, err
+}
+
+// fileExtension returns the extension of files with the given content type.
+func fileExtension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "image/gif" {
+		return "gif"
+	}
+
+	for _, profile := range dotmtx.Profiles() {
+		if profile.ContentType == mediaType {
+			return profile.Extension
+		}
+	}
+
+	return "bin"
+}
+
+// renderFile returns the file id when known, the render URL otherwise.
+func renderFile(opts dotmtx.Options, fileID string) tgbotapi.RequestFileData {
+	if fileID != "" {
+		return tgbotapi.FileID(fileID)
+	}
+	return tgbotapi.FileURL(renderURL(opts))
+}
+
+// sentFileID returns the id of the animation contained in message.
+func sentFileID(message *tgbotapi.Message) string {
+	switch {
+	case message.Animation != nil:
+		return message.Animation.FileID
+	case message.Video != nil:
+		return message.Video.FileID
+	case message.Document != nil:
+		return message.Document.FileID
+	default:
+		return ""
+	}
+}
+
+// adjustmentFlags maps each adjustment to its description
+var adjustmentFlags = []struct {
+	adj dotmtx.Adjustment
+	msg i18n.Message
+}{
+	{dotmtx.AdjustDots, i18n.AdjustedDots},
+	{dotmtx.AdjustPadding, i18n.AdjustedPadding},
+	{dotmtx.AdjustWidth, i18n.AdjustedWidth},
+	{dotmtx.AdjustBlank, i18n.AdjustedBlank},
+}
+
+// adjustmentNote explains the changes made to fit an animation
+// within resource limits; it returns an empty string when there are none.
+func adjustmentNote(opts dotmtx.Options, lang string) string {
+	adj, ok := dotmtx.Fit(opts)
+	if !ok || adj == 0 {
+		return ""
+	}
+
+	var names []string
+	for _, flag := range adjustmentFlags {
+		if adj&flag.adj != 0 {
+			names = append(names, i18n.Text(lang, flag.msg))
+		}
 	}
 
-	return imgURLInfo.String(), nil
+	return i18n.Text(lang, i18n.Adjusted, strings.Join(names, ", "))
 }
 
 func handleRender(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	lang := userLang(update)
+
+	opts, ok := parseRenderCommand(bot, update, lang, i18n.RenderUsage)
+	if !ok {
+		return
+	}
+
+	// Telegram does not play WebM animations
+	if opts.Format == dotmtx.FormatWebM {
+		sendDownload(bot, update, opts, lang)
+		return
+	}
+
+	sendRender(bot, update, opts, lang)
+}
+
+// handleDownload is like handleRender, but sends
+// the animation as a document that can be saved.
+func handleDownload(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	lang := userLang(update)
+
+	opts, ok := parseRenderCommand(bot, update, lang, i18n.DownloadUsage)
+	if !ok {
+		return
+	}
+
+	sendDownload(bot, update, opts, lang)
+}
+
+// parseRenderCommand parses the arguments of a render command, or the text
+// of the message it replies to; it tells the user what is wrong
+// and returns false when they are not valid.
+func parseRenderCommand(bot *tgbotapi.BotAPI, update tgbotapi.Update, lang string, usage i18n.Message) (dotmtx.Options, bool) {
 	query := update.Message.CommandArguments()
 	if query == "" {
-		sendMessage(bot, update.Message.Chat.ID, "Some parameters are missing:\n/render [Speed] [Width] [Blank] [Text]\n\nJust ask if you need some /help")
-		return
+		sendMessage(bot, update.Message, i18n.Text(lang, usage))
+		return dotmtx.Options{}, false
+	}
+
+	var opts dotmtx.Options
+	var err error
+
+	// when replying to a message, render its text
+	if reply := update.Message.ReplyToMessage; reply != nil && (reply.Text != "" || reply.Caption != "") {
+		text, styles := messageText(reply)
+		opts, err = parseReplyQuery(query, text, styles)
+	} else {
+		opts, err = parseQuery(query)
 	}
 
-	imgURL, err := queryToURL(query)
 	if err != nil {
-		sendMessage(bot, update.Message.Chat.ID, err.Error())
-		return
+		sendMessage(bot, update.Message, errorText(err, lang))
+		return opts, false
 	}
 
-	msg := tgbotapi.NewAnimation(update.Message.Chat.ID, tgbotapi.FileURL(imgURL))
+	return opts, true
+}
 
-	if _, err := bot.Send(msg); err != nil {
+// sendRender sends the animation described by opts in response
+// to the message of update, with the tweaking keyboard attached.
+func sendRender(bot *tgbotapi.BotAPI, update tgbotapi.Update, opts dotmtx.Options, lang string) {
+	opts = withErrorLang(opts, lang)
+	key := renderKey(opts)
+	fileID, cached := files.Get(key)
+
+	msg := tgbotapi.NewAnimation(update.Message.Chat.ID, renderFile(opts, fileID))
+	threadReply(&msg.BaseChat, update.Message)
+	_, _, msg.Duration = renderInfo(opts)
+	msg.Caption = adjustmentNote(opts, lang)
+	msg.ReplyMarkup = tweakKeyboard(makeTweakState(opts), lang)
+
+	sent, err := bot.Send(msg)
+	if err != nil && cached {
+		// the file id may not be valid anymore, send the URL
+		files.Delete(key)
+		msg.File = renderFile(opts, "")
+		sent, err = bot.Send(msg)
+	}
+
+	if err != nil {
 		log.ErrorLogger.Print("tgbotapi: ", err)
 		log.WarningLogger.Printf("could not send rendered GIF (update_id=%v, chat_id=%v)", update.UpdateID, msg.ChatID)
+		return
 	}
+
+	if id := sentFileID(&sent); id != "" {
+		files.Put(key, id)
+	}
+
+	tweaks.put(tweakKey{sent.Chat.ID, sent.MessageID}, opts, lang, update.SentFrom().ID)
+
+	recorder.Record(stats.Command, opts)
+}
+
+// sendDownload sends the animation described by opts as a document
+// in response to the message of update.
+func sendDownload(bot *tgbotapi.BotAPI, update tgbotapi.Update, opts dotmtx.Options, lang string) {
+	opts = withErrorLang(opts, lang)
+	key := downloadKey(opts)
+	fileID, cached := files.Get(key)
+
+	var file tgbotapi.RequestFileData = tgbotapi.FileID(fileID)
+	if !cached {
+		fetched, err := fetchRender(opts)
+		if err != nil {
+			log.ErrorLogger.Print("http: ", err)
+			log.WarningLogger.Printf("could not fetch animation (update_id=%v)", update.UpdateID)
+			sendMessage(bot, update.Message, i18n.Text(lang, i18n.InternalError))
+			return
+		}
+		file = fetched
+	}
+
+	msg := tgbotapi.NewDocument(update.Message.Chat.ID, file)
+	threadReply(&msg.BaseChat, update.Message)
+	msg.Caption = adjustmentNote(opts, lang)
+	// keep GIFs as files instead of turning them into animations
+	msg.DisableContentTypeDetection = true
+
+	sent, err := bot.Send(msg)
+	if err != nil && cached {
+		// the file id may not be valid anymore, upload the file
+		files.Delete(key)
+
+		fetched, ferr := fetchRender(opts)
+		if ferr != nil {
+			log.ErrorLogger.Print("http: ", ferr)
+			log.WarningLogger.Printf("could not fetch animation (update_id=%v)", update.UpdateID)
+			sendMessage(bot, update.Message, i18n.Text(lang, i18n.InternalError))
+			return
+		}
+
+		msg.File = fetched
+		sent, err = bot.Send(msg)
+	}
+
+	if err != nil {
+		log.ErrorLogger.Print("tgbotapi: ", err)
+		log.WarningLogger.Printf("could not send rendered document (update_id=%v, chat_id=%v)", update.UpdateID, msg.ChatID)
+		return
+	}
+
+	if sent.Document != nil {
+		files.Put(key, sent.Document.FileID)
+	}
+
+	recorder.Record(stats.Command, opts)
 }
 
 func handleInlineQuery(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
-	imgURL, err := queryToURL(update.InlineQuery.Query)
+	opts, err := parseQuery(update.InlineQuery.Query)
 	if err != nil {
 		return
 	}
 
-	result := tgbotapi.NewInlineQueryResultMPEG4GIF(fmt.Sprintf("%x", md5.Sum([]byte(imgURL))), imgURL)
-	result.ThumbURL = imgURL
+	// Telegram only plays GIF and MP4 animations inline
+	if opts.Format == dotmtx.FormatWebM {
+		opts.Format = dotmtx.FormatMP4
+	}
+
+	opts = withErrorLang(opts, userLang(update))
+	imgURL := renderURL(opts)
+	resultID := fmt.Sprintf("%x", md5.Sum([]byte(imgURL)))
+	title := adjustmentNote(opts, userLang(update))
+	width, height, duration := renderInfo(opts)
+	gifResults := renderFormat(opts) == dotmtx.FormatGIF
+
+	var result interface{}
+	if gifResults {
+		gifResult := tgbotapi.NewInlineQueryResultGIF(resultID, imgURL)
+		gifResult.ThumbURL = thumbURL(opts)
+		gifResult.Title = title
+		gifResult.Width, gifResult.Height, gifResult.Duration = width, height, duration
+		result = gifResult
+	} else {
+		mp4Result := tgbotapi.NewInlineQueryResultMPEG4GIF(resultID, imgURL)
+		mp4Result.ThumbURL = thumbURL(opts)
+		mp4Result.Title = title
+		mp4Result.Width, mp4Result.Height, mp4Result.Duration = width, height, duration
+		result = mp4Result
+	}
 
 	// log.InfoLogger.Print(result)
 
@@ -187,12 +794,199 @@ func handleInlineQuery(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
 		IsPersonal:    true,
 	}
 
+	// animations already sent once need not be fetched again
+	key := renderKey(opts)
+	if fileID, ok := files.Get(key); ok {
+		var cachedResult interface{}
+		if gifResults {
+			gifResult := tgbotapi.NewInlineQueryResultCachedGIF(resultID, fileID)
+			gifResult.Title = title
+			cachedResult = gifResult
+		} else {
+			mp4Result := tgbotapi.NewInlineQueryResultCachedMPEG4GIF(resultID, fileID)
+			mp4Result.Title = title
+			cachedResult = mp4Result
+		}
+
+		cachedAnswer := answer
+		cachedAnswer.Results = []interface{}{cachedResult}
+
+		if _, err := bot.Request(cachedAnswer); err == nil {
+			return
+		}
+
+		// the file id may not be valid anymore, send the URL
+		files.Delete(key)
+	}
+
 	if _, err := bot.Request(answer); err != nil {
 		log.ErrorLogger.Print("tgbotapi: ", err)
 		log.WarningLogger.Printf("could not send inline query answer (update_id=%v, query_id=%v)", update.UpdateID, answer.InlineQueryID)
 	}
 }
 
+// registerCommands sets the command menu for each supported language;
+// the default language is also used for users with no translation.
+func registerCommands(bot *tgbotapi.BotAPI) {
+	commands := func(lang string) []tgbotapi.BotCommand {
+		return []tgbotapi.BotCommand{
+			{Command: "help", Description: i18n.Text(lang, i18n.HelpCommand)},
+			{Command: "options", Description: i18n.Text(lang, i18n.OptionsCommand)},
+			{Command: "render", Description: i18n.Text(lang, i18n.RenderCommand)},
+			{Command: "download", Description: i18n.Text(lang, i18n.DownloadCommand)},
+			{Command: "new", Description: i18n.Text(lang, i18n.NewCommand)},
+			{Command: "cancel", Description: i18n.Text(lang, i18n.CancelCommand)},
+		}
+	}
+
+	configs := []tgbotapi.SetMyCommandsConfig{tgbotapi.NewSetMyCommands(commands(i18n.DefaultLanguage)...)}
+	for _, lang := range i18n.Languages() {
+		configs = append(configs, tgbotapi.NewSetMyCommandsWithScopeAndLanguage(tgbotapi.NewBotCommandScopeDefault(), lang, commands(lang)...))
+	}
+
+	for _, config := range configs {
+		if _, err := bot.Request(config); err != nil {
+			log.ErrorLogger.Print("tgbotapi: ", err)
+			log.WarningLogger.Printf("could not register command menu (language_code=%q)", config.LanguageCode)
+		}
+	}
+}
+
+// handleChosenInlineResult counts inline results actually sent by users.
+// Telegram only delivers these updates when inline feedback
+// is enabled for the bot through @BotFather. They do not carry
+// the file id of the animation, so only animations sent by the bot
+// itself end up in the file id cache.
+func handleChosenInlineResult(update tgbotapi.Update) {
+	opts, err := parseQuery(update.ChosenInlineResult.Query)
+	if err != nil {
+		return
+	}
+
+	recorder.Record(stats.Inline, opts)
+}
+
+// statsDays is the number of days covered by the /stats chart
+const statsDays = 30
+
+// statsParams lists the parameters summarized by /stats
+var statsParams = []string{"speed", "width", "blank", "dwell", "bounce", "ease", "loops", "phase", "panel", "align", "overflow", "fps", "smooth", "color", "style", "format"}
+
+func handleStats(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	if !admins[update.SentFrom().ID] {
+		handleUnknownCommand(bot, update)
+		return
+	}
+
+	counters := recorder.Snapshot()
+	daily := counters.Daily(time.Now(), statsDays)
+
+	lang := userLang(update)
+
+	var summary strings.Builder
+
+	summary.WriteString(i18n.Text(lang, i18n.StatsRenders, counters.Total(), daily[statsDays-1], sum(daily), statsDays) + "\n")
+	if total := counters.Total(); total > 0 {
+		summary.WriteString(i18n.Text(lang, i18n.StatsSources,
+			counters.Inline, 100*float64(counters.Inline)/float64(total),
+			counters.Command, 100*float64(counters.Command)/float64(total)) + "\n")
+	}
+
+	for _, param := range statsParams {
+		top := counters.Top(param, 5)
+		if len(top) == 0 {
+			continue
+		}
+
+		values := make([]string, len(top))
+		for i, bucket := range top {
+			values[i] = fmt.Sprintf("%s (%d)", bucket.Value, bucket.Count)
+		}
+
+		fmt.Fprintf(&summary, "\n%s: %s", param, strings.Join(values, ", "))
+	}
+
+	var chart bytes.Buffer
+	if err := png.Encode(&chart, dotmtx.MakeChart(daily, 16)); err != nil {
+		log.ErrorLogger.Print("png: ", err)
+		log.WarningLogger.Print("could not encode stats chart")
+		sendMessage(bot, update.Message, summary.String())
+		return
+	}
+
+	msg := tgbotapi.NewPhoto(update.Message.Chat.ID, tgbotapi.FileBytes{Name: "stats.png", Bytes: chart.Bytes()})
+	threadReply(&msg.BaseChat, update.Message)
+
+	// captions are limited to 1024 characters
+	longSummary := len([]rune(summary.String())) > 1024
+	if !longSummary {
+		msg.Caption = summary.String()
+	}
+
+	if _, err := bot.Send(msg); err != nil {
+		log.ErrorLogger.Print("tgbotapi: ", err)
+		log.WarningLogger.Printf("could not send stats (update_id=%v, chat_id=%v)", update.UpdateID, msg.ChatID)
+		return
+	}
+
+	if longSummary {
+		sendMessage(bot, update.Message, summary.String())
+	}
+}
+
+// handleBlock adds a user to the blocklist, or removes them when block
+// is false. The user id is given as argument or taken from the sender
+// of the replied-to message.
+func handleBlock(bot *tgbotapi.BotAPI, update tgbotapi.Update, block bool) {
+	if !admins[update.SentFrom().ID] {
+		handleUnknownCommand(bot, update)
+		return
+	}
+
+	lang := userLang(update)
+
+	var userID int64
+	var err error
+
+	if reply := update.Message.ReplyToMessage; reply != nil && reply.From != nil && update.Message.CommandArguments() == "" {
+		userID = reply.From.ID
+	} else if userID, err = strconv.ParseInt(strings.TrimSpace(update.Message.CommandArguments()), 10, 64); err != nil {
+		sendMessage(bot, update.Message, i18n.Text(lang, i18n.BlockUsage, update.Message.Command()))
+		return
+	}
+
+	if admins[userID] {
+		sendMessage(bot, update.Message, i18n.Text(lang, i18n.BlockAdmin))
+		return
+	}
+
+	if block {
+		err = moderator.Block(userID)
+	} else {
+		err = moderator.Unblock(userID)
+	}
+
+	if err != nil {
+		log.ErrorLogger.Print("moderation: ", err)
+		log.WarningLogger.Print("could not save blocklist")
+		sendMessage(bot, update.Message, i18n.Text(lang, i18n.BlockSaveFailed))
+		return
+	}
+
+	if block {
+		sendMessage(bot, update.Message, i18n.Text(lang, i18n.UserBlocked, userID))
+	} else {
+		sendMessage(bot, update.Message, i18n.Text(lang, i18n.UserUnblocked, userID))
+	}
+}
+
+func sum(values []int) (total int) {
+	for _, value := range values {
+		total += value
+	}
+	return
+}
+
 func main() {
 	tgbotapi.SetLogger(log.InfoLogger)
 
@@ -205,6 +999,56 @@ func main() {
 	bot.Debug = false
 	log.InfoLogger.Printf("authorized on account %s", bot.Self.UserName)
 
+	registerCommands(bot)
+
+	recorder, err = stats.Open(statsPath)
+	if err != nil {
+		log.ErrorLogger.Print("stats: ", err)
+		log.FatalLogger.Fatal("could not load usage statistics")
+	}
+
+	go recorder.AutoSave(time.Minute)
+
+	moderator, err = moderation.Load(moderationRulesPath, blocklistPath)
+	if err != nil {
+		log.ErrorLogger.Print("moderation: ", err)
+		log.FatalLogger.Fatal("could not load moderation rules or blocklist")
+	}
+
+	dotmtx.TextFilter = moderator.AllowText
+	go moderator.LogCounts(time.Hour)
+
+	files, err = filecache.Open(fileCachePath, fileCacheTTL)
+	if err != nil {
+		log.ErrorLogger.Print("filecache: ", err)
+		log.FatalLogger.Fatal("could not load file id cache")
+	}
+
+	go files.AutoSave(time.Minute)
+
+	if err := dotmtx.LoadProfiles(videoProfilesPath); err != nil {
+		log.ErrorLogger.Print("dotmtx: ", err)
+		log.FatalLogger.Fatal("could not load video profiles")
+	}
+
+	probeCtx, cancelProbe := context.WithTimeout(context.Background(), 10*time.Second)
+	if info, err := dotmtx.ProbeFFmpeg(probeCtx); err != nil {
+		log.ErrorLogger.Print("ffmpeg: ", err)
+		log.WarningLogger.Print("could not probe ffmpeg")
+	} else {
+		log.InfoLogger.Printf("found ffmpeg %s with %d video encoders", info.Version, len(info.Encoders))
+	}
+	cancelProbe()
+
+	if !dotmtx.VideoAvailable() {
+		log.WarningLogger.Print("video conversion unavailable, animations will be sent as GIF")
+	}
+
+	// texts are not stored, so keyboards do not survive restarts
+	log.InfoLogger.Print("tweaking keyboards of animations sent before this start have expired")
+	go tweaks.expire(time.Hour)
+	go wizards.expire(time.Minute)
+
 	updateConfig := tgbotapi.NewUpdate(0)
 	updateConfig.Timeout = 60
 
@@ -213,7 +1057,10 @@ func main() {
 	// start http server
 	go func() {
 		http.HandleFunc(gifPath, dotmtx.GifHandler)
-		http.HandleFunc(mp4Path, dotmtx.Mp4Handler)
+		http.HandleFunc(mp4Path, dotmtx.VideoHandler)
+		http.HandleFunc(webmPath, dotmtx.VideoHandler)
+		http.HandleFunc(thumbPath, dotmtx.ThumbHandler)
+		http.HandleFunc("/", dotmtx.PlaygroundHandler(gifPath, bot.Self.UserName))
 
 		err := http.ListenAndServe(imgServiceAddr, nil)
 		if err != http.ErrServerClosed {
@@ -223,19 +1070,49 @@ func main() {
 	}()
 
 	for update := range updates {
+		if user := update.SentFrom(); user != nil && moderator.Blocked(user.ID) {
+			continue
+		}
+
 		if update.Message != nil && update.Message.IsCommand() {
+			if !addressedToBot(bot, update.Message) {
+				continue
+			}
+
 			switch update.Message.Command() {
 			case "start", "help":
 				go handleHelp(bot, update)
+			case "options":
+				go handleOptions(bot, update)
 			case "render":
 				go handleRender(bot, update)
+			case "download":
+				go handleDownload(bot, update)
+			case "new":
+				go handleNew(bot, update)
+			case "cancel":
+				go handleCancel(bot, update)
+			case "stats":
+				go handleStats(bot, update)
+			case "block":
+				go handleBlock(bot, update, true)
+			case "unblock":
+				go handleBlock(bot, update, false)
 			case "haha":
-				go sendMessage(bot, update.Message.Chat.ID, "LOL haha classic")
+				if !isGroup(update.Message.Chat) {
+					go sendMessage(bot, update.Message, i18n.Text(userLang(update), i18n.Haha))
+				}
 			default:
-				go sendMessage(bot, update.Message.Chat.ID, "I don't know that command")
+				go handleUnknownCommand(bot, update)
 			}
 		} else if update.InlineQuery != nil {
 			go handleInlineQuery(bot, update)
+		} else if update.ChosenInlineResult != nil {
+			go handleChosenInlineResult(update)
+		} else if update.Message != nil && update.Message.Chat.IsPrivate() {
+			go handleWizardMessage(bot, update)
+		} else if update.CallbackQuery != nil {
+			go handleCallbackQuery(bot, update)
 		}
 	}
 
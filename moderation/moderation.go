@@ -0,0 +1,276 @@
+// Package moderation refuses abusive texts and ignores blocked users.
+// Refusals are only counted, never logged with their content.
+package moderation
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"petbots.fbbdev.it/dotmtxbot/log"
+	"petbots.fbbdev.it/dotmtxbot/persist"
+)
+
+// Moderator checks texts against a list of rules
+// and users against a persistent blocklist.
+// It is safe for concurrent use.
+type Moderator struct {
+	words   []string
+	regexps []*regexp.Regexp
+
+	blocklistPath string
+
+	mu      sync.Mutex
+	blocked map[int64]bool
+
+	refusedTexts   atomic.Int64
+	refusedUpdates atomic.Int64
+}
+
+// Load reads the rules at rulesPath and the blocklist at blocklistPath;
+// missing files are treated as empty.
+//
+// The rules file holds one rule per line; lines starting with # are
+// comments. Rules enclosed in slashes, like /fr[e3]+ money/, are regular
+// expressions, any other line is a word or phrase matched as a whole.
+// Both are applied to the normalized text (see Normalize).
+//
+// The blocklist file holds one user id per line.
+func Load(rulesPath string, blocklistPath string) (*Moderator, error) {
+	m := &Moderator{
+		blocklistPath: blocklistPath,
+		blocked:       map[int64]bool{},
+	}
+
+	err := readLines(rulesPath, func(line string) error {
+		if len(line) > 2 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+			re, err := regexp.Compile(line[1 : len(line)-1])
+			if err != nil {
+				return err
+			}
+			m.regexps = append(m.regexps, re)
+		} else if word := Normalize(line); word != "" {
+			m.words = append(m.words, word)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readLines(blocklistPath, func(line string) error {
+		id, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return err
+		}
+		m.blocked[id] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// readLines calls fn for each non-empty line of the file
+// at path that is not a comment.
+func readLines(path string, fn func(line string) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// symbolLookAlikes maps digits and symbols commonly used to dodge
+// filters to the letters they resemble
+var symbolLookAlikes = map[rune]rune{
+	'0': 'o', '1': 'i', '!': 'i', '|': 'i', '3': 'e', '4': 'a', '@': 'a',
+	'5': 's', '$': 's', '7': 't', '+': 't', '8': 'b', '9': 'g',
+}
+
+// letterLookAlikes maps letters of other scripts
+// to the latin letters they resemble
+var letterLookAlikes = map[rune]rune{
+	// cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'і': 'i', 'ј': 'j', 'к': 'k', 'м': 'm',
+	'н': 'h', 'һ': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'ѕ': 's', 'т': 't',
+	'у': 'y', 'х': 'x', 'ԁ': 'd', 'ӏ': 'l',
+	// greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+}
+
+// accented letters followed by their base letter
+var accents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a",
+	"ç", "c", "è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ý", "y", "ÿ", "y",
+)
+
+// Normalize lowercases text, removes accents, replaces look-alike
+// characters with the letters they resemble and turns anything else
+// that is not a letter into a single space. Digits and symbols are only
+// replaced when followed by a letter or another look-alike, so that
+// trailing punctuation is not mistaken for a letter.
+func Normalize(text string) string {
+	runes := []rune(accents.Replace(strings.ToLower(text)))
+
+	for i, r := range runes {
+		if l, ok := letterLookAlikes[r]; ok {
+			runes[i] = l
+		}
+	}
+
+	// map symbols right to left, so that runs of look-alikes are mapped
+	// as long as the last one is followed by a letter
+	for i := len(runes) - 2; i >= 0; i-- {
+		if l, ok := symbolLookAlikes[runes[i]]; ok && unicode.IsLetter(runes[i+1]) {
+			runes[i] = l
+		}
+	}
+
+	var b strings.Builder
+	space := true
+
+	for _, r := range runes {
+		if unicode.IsLetter(r) {
+			b.WriteRune(r)
+			space = false
+		} else if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// AllowText reports whether text may be rendered.
+// Words spelled out with separators, like s p a m, are caught too.
+func (m *Moderator) AllowText(text string) bool {
+	normalized := Normalize(text)
+
+	// join runs of single letters
+	var joined []string
+	run := ""
+	for _, word := range strings.Fields(normalized) {
+		if utf8.RuneCountInString(word) == 1 {
+			run += word
+			continue
+		}
+		if run != "" {
+			joined, run = append(joined, run), ""
+		}
+		joined = append(joined, word)
+	}
+	if run != "" {
+		joined = append(joined, run)
+	}
+
+	candidates := []string{" " + normalized + " ", " " + strings.Join(joined, " ") + " "}
+
+	for _, candidate := range candidates {
+		for _, word := range m.words {
+			if strings.Contains(candidate, " "+word+" ") {
+				m.refusedTexts.Add(1)
+				return false
+			}
+		}
+
+		for _, re := range m.regexps {
+			if re.MatchString(candidate) {
+				m.refusedTexts.Add(1)
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Blocked reports whether updates from the given user must be ignored;
+// ignored updates are counted.
+func (m *Moderator) Blocked(userID int64) bool {
+	m.mu.Lock()
+	blocked := m.blocked[userID]
+	m.mu.Unlock()
+
+	if blocked {
+		m.refusedUpdates.Add(1)
+	}
+
+	return blocked
+}
+
+// Block adds a user to the blocklist and saves it.
+func (m *Moderator) Block(userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.blocked[userID] = true
+	return m.save()
+}
+
+// Unblock removes a user from the blocklist and saves it.
+func (m *Moderator) Unblock(userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.blocked, userID)
+	return m.save()
+}
+
+// save writes the blocklist atomically; m.mu must be held.
+func (m *Moderator) save() error {
+	ids := make([]int64, 0, len(m.blocked))
+	for id := range m.blocked {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var b strings.Builder
+	for _, id := range ids {
+		b.WriteString(strconv.FormatInt(id, 10))
+		b.WriteByte('\n')
+	}
+
+	return persist.WriteFile(m.blocklistPath, []byte(b.String()))
+}
+
+// LogCounts logs the number of refusals at the given interval, forever.
+func (m *Moderator) LogCounts(interval time.Duration) {
+	for range time.Tick(interval) {
+		texts, updates := m.refusedTexts.Swap(0), m.refusedUpdates.Swap(0)
+		if texts > 0 || updates > 0 {
+			log.InfoLogger.Printf("moderation: refused %d texts and ignored %d updates from blocked users in the last %v", texts, updates, interval)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected string
+	}{
+		{"Hello, World!", "hello world"},
+		{"SPAM!!!", "spam"},
+		{"Spâm è bello", "spam e bello"},
+		{"5P4M", "spam"},
+		{"fr3e m0ney", "free money"},
+		{"fr33 m0ney", "fr money"},
+		{"$$pam", "sspam"},
+		{"ѕраm", "spam"},
+		{"ναν", "vav"},
+		{"s.p.a.m", "s p a m"},
+		{"  tabs\tand\nnewlines  ", "tabs and newlines"},
+		{"100% 42", ""},
+		{"it's 4 u", "it s u"},
+		{"", ""},
+	}
+
+	for _, c := range cases {
+		if actual := Normalize(c.text); actual != c.expected {
+			t.Errorf("Normalize(%q) = %q; expected %q", c.text, actual, c.expected)
+		}
+	}
+}
+
+func TestAllowText(t *testing.T) {
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "moderation.conf")
+
+	err := os.WriteFile(rules, []byte("# comment\nspam\nFree Money\n/fre+ mone+y/\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Load(rules, filepath.Join(dir, "blocklist.txt"))
+	if err != nil {
+		t.Fatal("Load: ", err)
+	}
+
+	cases := []struct {
+		text    string
+		allowed bool
+	}{
+		{"HELLO WORLD", true},
+		{"SPAM", false},
+		{"I like spam", false},
+		{"5P4M!", false},
+		{"s.p.a.m", false},
+		{"s p a m alot", false},
+		{"spammer", true},
+		{"ham", true},
+		{"comment", true},
+		{"free money", false},
+		{"FR3E   M0NEY", false},
+		{"freeee money", false},
+		{"free monkey", true},
+		{"money for free", true},
+	}
+
+	for _, c := range cases {
+		if allowed := m.AllowText(c.text); allowed != c.allowed {
+			t.Errorf("AllowText(%q) = %v; expected %v", c.text, allowed, c.allowed)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Package persist saves the state of the bot to disk.
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"petbots.fbbdev.it/dotmtxbot/log"
+)
+
+// WriteFile replaces the file at path with data atomically,
+// so that a crash cannot leave it corrupted. Missing parent
+// directories are created.
+func WriteFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Save writes the data returned by marshal to path with WriteFile
+// if *dirty is set, then clears it. mu guards dirty and the state
+// read by marshal; it is not held while writing. On failure
+// *dirty is set again so that the next save tries again.
+func Save(mu sync.Locker, dirty *bool, path string, marshal func() ([]byte, error)) error {
+	mu.Lock()
+	if !*dirty {
+		mu.Unlock()
+		return nil
+	}
+	data, err := marshal()
+	*dirty = false
+	mu.Unlock()
+
+	if err == nil {
+		err = WriteFile(path, data)
+	}
+
+	if err != nil {
+		mu.Lock()
+		*dirty = true
+		mu.Unlock()
+	}
+
+	return err
+}
+
+// AutoSave calls save at the given interval, forever. Failures are logged
+// with the package name and a description of what could not be saved;
+// save must keep its changes pending so that the next tick tries again.
+func AutoSave(interval time.Duration, save func() error, pkg string, what string) {
+	for range time.Tick(interval) {
+		if err := save(); err != nil {
+			log.ErrorLogger.Print(pkg+": ", err)
+			log.WarningLogger.Print("could not save " + what)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package stats keeps anonymous usage counters for the bot.
+// Only aggregate counts are stored: no text, user or chat information.
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"petbots.fbbdev.it/dotmtxbot/dotmtx"
+	"petbots.fbbdev.it/dotmtxbot/persist"
+)
+
+// Source identifies how an animation was requested.
+type Source int
+
+const (
+	Inline Source = iota
+	Command
+)
+
+// dayFormat is the layout of the keys of Counters.Days
+const dayFormat = "2006-01-02"
+
+// Counters holds the aggregated usage statistics.
+type Counters struct {
+	// renders per day, keyed by UTC date
+	Days map[string]int `json:"days"`
+	// renders per source
+	Inline  int `json:"inline"`
+	Command int `json:"command"`
+	// histograms of parameter values, keyed by parameter name
+	Params map[string]map[string]int `json:"params"`
+}
+
+// Bucket is a parameter value along with its count.
+type Bucket struct {
+	Value string
+	Count int
+}
+
+// Total returns the total number of renders.
+func (c *Counters) Total() int {
+	return c.Inline + c.Command
+}
+
+// Daily returns the number of renders in each of the n days
+// up to and including the day of end, oldest first.
+func (c *Counters) Daily(end time.Time, n int) []int {
+	counts := make([]int, n)
+	end = end.UTC()
+	for i := range counts {
+		counts[i] = c.Days[end.AddDate(0, 0, i-n+1).Format(dayFormat)]
+	}
+	return counts
+}
+
+// Top returns the n most frequent values of the given parameter.
+func (c *Counters) Top(param string, n int) []Bucket {
+	var buckets []Bucket
+	for value, count := range c.Params[param] {
+		buckets = append(buckets, Bucket{value, count})
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Value < buckets[j].Value
+	})
+
+	if len(buckets) > n {
+		buckets = buckets[:n]
+	}
+
+	return buckets
+}
+
+// Recorder accumulates counters and persists them to a JSON file.
+// It is safe for concurrent use.
+type Recorder struct {
+	path string
+
+	mu       sync.Mutex
+	counters Counters
+	dirty    bool
+}
+
+// Open returns a Recorder persisting counters at path,
+// loading the counters saved there if any.
+func Open(path string) (*Recorder, error) {
+	r := &Recorder{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	} else if err == nil {
+		if err := json.Unmarshal(data, &r.counters); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.counters.Days == nil {
+		r.counters.Days = map[string]int{}
+	}
+	if r.counters.Params == nil {
+		r.counters.Params = map[string]map[string]int{}
+	}
+
+	return r, nil
+}
+
+// bucket rounds numeric parameter values to one decimal place
+// so that histograms do not grow a bucket for every request.
+func bucket(value string) string {
+	x, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(x, 0) || math.IsNaN(x) {
+		return value
+	}
+	return strconv.FormatFloat(math.Round(x*10)/10, 'f', -1, 64)
+}
+
+// Record counts a render of the animation described by opts.
+// The text is never recorded.
+func (r *Recorder) Record(source Source, opts dotmtx.Options) {
+	params := opts.Values()
+	params.Del("text")
+	// it only applies to error animations
+	params.Del("lang")
+
+	// span positions would grow a bucket for almost every request
+	// and tell about the text, only the styles used are counted
+	if len(opts.Styles) > 0 {
+		var used dotmtx.Style
+		for _, span := range opts.Styles {
+			used |= span.Style
+		}
+		params.Set("style", used.String())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counters.Days[time.Now().UTC().Format(dayFormat)]++
+
+	switch source {
+	case Inline:
+		r.counters.Inline++
+	case Command:
+		r.counters.Command++
+	}
+
+	for name := range params {
+		histogram := r.counters.Params[name]
+		if histogram == nil {
+			histogram = map[string]int{}
+			r.counters.Params[name] = histogram
+		}
+		histogram[bucket(params.Get(name))]++
+	}
+
+	r.dirty = true
+}
+
+// Snapshot returns a copy of the current counters.
+func (r *Recorder) Snapshot() Counters {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := Counters{
+		Days:    make(map[string]int, len(r.counters.Days)),
+		Inline:  r.counters.Inline,
+		Command: r.counters.Command,
+		Params:  make(map[string]map[string]int, len(r.counters.Params)),
+	}
+
+	for day, count := range r.counters.Days {
+		c.Days[day] = count
+	}
+
+	for name, histogram := range r.counters.Params {
+		c.Params[name] = make(map[string]int, len(histogram))
+		for value, count := range histogram {
+			c.Params[name][value] = count
+		}
+	}
+
+	return c
+}
+
+// Save writes the counters to disk if they changed since the last save.
+func (r *Recorder) Save() error {
+	return persist.Save(&r.mu, &r.dirty, r.path, func() ([]byte, error) {
+		return json.Marshal(&r.counters)
+	})
+}
+
+// AutoSave saves the counters at the given interval, forever.
+func (r *Recorder) AutoSave(interval time.Duration) {
+	persist.AutoSave(interval, r.Save, "stats", "usage statistics")
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"petbots.fbbdev.it/dotmtxbot/dotmtx"
+	"petbots.fbbdev.it/dotmtxbot/i18n"
+	"petbots.fbbdev.it/dotmtxbot/log"
+)
+
+// actions of the tweaking keyboard, as found in callback data
+const (
+	tweakSlower   = 's'
+	tweakFaster   = 'f'
+	tweakReverse  = 'r'
+	tweakNarrower = 'n'
+	tweakWider    = 'w'
+	tweakColor    = 'c'
+)
+
+// tweaking limits
+const (
+	tweakFactor   = 1.5
+	tweakMinSpeed = 0.25
+	tweakMaxSpeed = 60
+	tweakMinWidth = 0.1
+)
+
+// tweakState holds the options changed by the tweaking keyboard.
+// Each button carries the state of the animation it is attached to,
+// so that pressing buttons of an outdated keyboard works as expected.
+type tweakState struct {
+	speed float64
+	width float64
+	blank float64
+	color dotmtx.Color
+}
+
+func makeTweakState(opts dotmtx.Options) tweakState {
+	return tweakState{opts.Speed, opts.Width, opts.Blank, opts.Color}
+}
+
+// encode returns the callback data for action, in the form
+// t<action><speed>,<width>,<blank>,<color>; it is well within
+// the 64 byte limit imposed by Telegram.
+func (st tweakState) encode(action byte) string {
+	format := func(x float64) string { return strconv.FormatFloat(x, 'g', 6, 64) }
+	return fmt.Sprintf("t%c%s,%s,%s,%d", action, format(st.speed), format(st.width), format(st.blank), st.color)
+}
+
+// decodeTweak parses callback data produced by tweakState.encode.
+func decodeTweak(data string) (action byte, st tweakState, ok bool) {
+	if len(data) < 2 || data[0] != 't' {
+		return 0, st, false
+	}
+
+	fields := strings.Split(data[2:], ",")
+	if len(fields) != 4 {
+		return 0, st, false
+	}
+
+	var err [4]error
+	st.speed, err[0] = strconv.ParseFloat(fields[0], 64)
+	st.width, err[1] = strconv.ParseFloat(fields[1], 64)
+	st.blank, err[2] = strconv.ParseFloat(fields[2], 64)
+
+	var color int
+	color, err[3] = strconv.Atoi(fields[3])
+	st.color = dotmtx.Color(color)
+
+	for _, e := range err {
+		if e != nil {
+			return 0, st, false
+		}
+	}
+
+	return data[1], st, true
+}
+
+// apply returns the state resulting from action on the animation
+// described by base; it reports false when a limit has been reached.
+func (st tweakState) apply(action byte, base dotmtx.Options) (tweakState, bool) {
+	switch action {
+	case tweakSlower:
+		if math.Abs(st.speed)/tweakFactor < tweakMinSpeed {
+			return st, false
+		}
+		st.speed /= tweakFactor
+	case tweakFaster:
+		if st.speed == 0 {
+			st.speed = dotmtx.DefaultScrollSpeed
+		} else if math.Abs(st.speed)*tweakFactor > tweakMaxSpeed {
+			return st, false
+		} else {
+			st.speed *= tweakFactor
+		}
+	case tweakReverse:
+		if st.speed == 0 {
+			return st, false
+		}
+		st.speed = -st.speed
+	case tweakNarrower:
+		if st.width/tweakFactor < tweakMinWidth {
+			return st, false
+		}
+		st.width /= tweakFactor
+	case tweakWider:
+		// beyond the limit the display does not get any wider
+		limit := dotmtx.WidthLimit(st.options(base))
+		if st.width >= limit {
+			return st, false
+		}
+		st.width = math.Min(st.width*tweakFactor, limit)
+	case tweakColor:
+		st.color = st.color.Next()
+	default:
+		return st, false
+	}
+
+	return st, true
+}
+
+// options returns a copy of base with the tweaked options applied.
+func (st tweakState) options(base dotmtx.Options) dotmtx.Options {
+	base.Speed, base.Width, base.Blank, base.Color = st.speed, st.width, st.blank, st.color
+	return base
+}
+
+func tweakKeyboard(st tweakState, lang string) tgbotapi.InlineKeyboardMarkup {
+	button := func(msg i18n.Message, action byte) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(i18n.Text(lang, msg), st.encode(action))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(i18n.SlowerButton, tweakSlower),
+			button(i18n.FasterButton, tweakFaster),
+			button(i18n.ReverseButton, tweakReverse),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(i18n.NarrowerButton, tweakNarrower),
+			button(i18n.WiderButton, tweakWider),
+			button(i18n.ColorButton, tweakColor),
+		),
+	)
+}
+
+// tweakLifetime is how long animations can be tweaked after the last change
+const tweakLifetime = 48 * time.Hour
+
+type tweakKey struct {
+	chatID    int64
+	messageID int
+}
+
+type tweakEntry struct {
+	// opts holds the text and the options not covered by tweakState
+	opts dotmtx.Options
+	lang string
+	// userID is the user who asked for the animation,
+	// the only one allowed to tweak it
+	userID  int64
+	expires time.Time
+}
+
+// tweakStore keeps in memory the options of animations that can be tweaked.
+// Entries are never written to disk, as they hold the text of the animation
+// and the privacy notice promises that texts are not stored: after a restart,
+// all keyboards sent before it answer TweakExpired.
+type tweakStore struct {
+	mu      sync.Mutex
+	entries map[tweakKey]tweakEntry
+}
+
+var tweaks = tweakStore{entries: map[tweakKey]tweakEntry{}}
+
+func (ts *tweakStore) put(key tweakKey, opts dotmtx.Options, lang string, userID int64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.entries[key] = tweakEntry{opts, lang, userID, time.Now().Add(tweakLifetime)}
+}
+
+// get returns the entry for key and extends its lifetime.
+func (ts *tweakStore) get(key tweakKey) (tweakEntry, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	entry, ok := ts.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return entry, false
+	}
+
+	entry.expires = time.Now().Add(tweakLifetime)
+	ts.entries[key] = entry
+
+	return entry, true
+}
+
+// expire removes expired entries at the given interval, forever.
+func (ts *tweakStore) expire(interval time.Duration) {
+	for now := range time.Tick(interval) {
+		ts.mu.Lock()
+		for key, entry := range ts.entries {
+			if now.After(entry.expires) {
+				delete(ts.entries, key)
+			}
+		}
+		ts.mu.Unlock()
+	}
+}
+
+func handleCallbackQuery(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	query := update.CallbackQuery
+
+	answer := func(text string) {
+		if _, err := bot.Request(tgbotapi.NewCallback(query.ID, text)); err != nil {
+			log.ErrorLogger.Print("tgbotapi: ", err)
+			log.WarningLogger.Printf("could not answer callback query (update_id=%v, query_id=%v)", update.UpdateID, query.ID)
+		}
+	}
+
+	action, st, ok := decodeTweak(query.Data)
+	if !ok || query.Message == nil {
+		answer("")
+		return
+	}
+
+	lang := i18n.Lang(query.From.LanguageCode)
+	key := tweakKey{query.Message.Chat.ID, query.Message.MessageID}
+
+	entry, ok := tweaks.get(key)
+	if !ok {
+		answer(i18n.Text(lang, i18n.TweakExpired))
+		return
+	}
+
+	// in groups, others must not change someone else's animation
+	if query.From.ID != entry.userID {
+		answer(i18n.Text(lang, i18n.TweakNotOwner))
+		return
+	}
+
+	st, ok = st.apply(action, entry.opts)
+	opts := st.options(entry.opts)
+
+	if ok && opts.Validate() != nil {
+		ok = false
+	}
+
+	// widening makes no sense when the width is lowered to fit
+	if adj, fits := dotmtx.Fit(opts); !fits || (action == tweakWider && adj&dotmtx.AdjustWidth != 0) {
+		ok = false
+	}
+
+	if !ok {
+		answer(i18n.Text(lang, i18n.TweakLimit))
+		return
+	}
+
+	fileKey := renderKey(opts)
+	fileID, cached := files.Get(fileKey)
+
+	media := tgbotapi.NewInputMediaAnimation(renderFile(opts, fileID))
+	media.Caption = adjustmentNote(opts, entry.lang)
+
+	keyboard := tweakKeyboard(st, entry.lang)
+
+	edit := tgbotapi.EditMessageMediaConfig{
+		BaseEdit: tgbotapi.BaseEdit{
+			ChatID:      key.chatID,
+			MessageID:   key.messageID,
+			ReplyMarkup: &keyboard,
+		},
+		Media: media,
+	}
+
+	edited, err := bot.Send(edit)
+	if err != nil && cached {
+		// the file id may not be valid anymore, send the URL
+		files.Delete(fileKey)
+		media.Media = renderFile(opts, "")
+		edit.Media = media
+		edited, err = bot.Send(edit)
+	}
+
+	if err != nil {
+		log.ErrorLogger.Print("tgbotapi: ", err)
+		log.WarningLogger.Printf("could not edit rendered GIF (update_id=%v, chat_id=%v)", update.UpdateID, key.chatID)
+		answer(i18n.Text(lang, i18n.InternalError))
+		return
+	}
+
+	if id := sentFileID(&edited); id != "" {
+		files.Put(fileKey, id)
+	}
+
+	answer("")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"petbots.fbbdev.it/dotmtxbot/dotmtx"
+)
+
+func TestDecodeTweak(t *testing.T) {
+	cases := []struct {
+		action byte
+		st     tweakState
+	}{
+		{tweakSlower, tweakState{4, 1, 1, dotmtx.ColorAmber}},
+		{tweakFaster, tweakState{0, 0.5, 0, dotmtx.ColorWhite}},
+		{tweakReverse, tweakState{-13.5, 2.25, 0.125, dotmtx.ColorRed}},
+		{tweakNarrower, tweakState{0.296296, 0.131687, 100, dotmtx.ColorBlue}},
+		{tweakWider, tweakState{-1.23457e-300, 1.23457e+300, 9.87654e+299, dotmtx.ColorGreen}},
+		{tweakColor, tweakState{60, 16384, 1e-05, dotmtx.ColorWhite}},
+	}
+
+	for _, c := range cases {
+		data := c.st.encode(c.action)
+		if len(data) > 64 {
+			t.Errorf("%q is %d bytes long, more than the 64 allowed by Telegram", data, len(data))
+		}
+
+		action, st, ok := decodeTweak(data)
+		if !ok || action != c.action || st != c.st {
+			t.Errorf("decodeTweak(%q) = %q, %v, %v; expected %q, %v, true", data, action, st, ok, c.action, c.st)
+		}
+	}
+}
+
+func TestDecodeTweakInvalid(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"t",
+		"x4,1,1,0",
+		"ts4,1,1",
+		"ts4,1,1,0,0",
+		"tsfast,1,1,0",
+		"ts4,1,1,red",
+		"ts4,,1,0",
+	} {
+		if _, _, ok := decodeTweak(data); ok {
+			t.Errorf("decodeTweak(%q) succeeded", data)
+		}
+	}
+}
+
+func TestTweakWider(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     dotmtx.Options
+		expected []float64
+	}{
+		{"loop", dotmtx.Options{Speed: 4, Width: 1, Blank: 1}, []float64{1.5, 2}},
+		{"static", dotmtx.Options{Width: 0.5, Blank: 0.5}, []float64{0.75, 1.125, 1.5}},
+		{"beyond", dotmtx.Options{Speed: 4, Width: 5, Blank: 1}, nil},
+		{"bounce", dotmtx.Options{Speed: 4, Width: 1, Blank: 0, Bounce: true}, []float64{1.5, 2.25, 3.375, 5.0625, 7.59375, 11.390625, 17.0859375, 25.62890625}},
+		{"panel", dotmtx.Options{Width: 1, Blank: 1, PanelColumns: 64, PanelRows: 16}, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			st := makeTweakState(c.opts)
+
+			// bouncing text is only bounded by resource limits,
+			// which are checked by the caller
+			var widths []float64
+			for range 8 {
+				next, ok := st.apply(tweakWider, c.opts)
+				if !ok {
+					break
+				}
+				st = next
+				widths = append(widths, st.width)
+			}
+
+			if len(widths) != len(c.expected) {
+				t.Fatalf("widths %v; expected %v", widths, c.expected)
+			}
+			for i := range widths {
+				if math.Abs(widths[i]-c.expected[i]) > 1e-9 {
+					t.Fatalf("widths %v; expected %v", widths, c.expected)
+				}
+			}
+		})
+	}
+}
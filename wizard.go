@@ -0,0 +1,278 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"petbots.fbbdev.it/dotmtxbot/dotmtx"
+	"petbots.fbbdev.it/dotmtxbot/i18n"
+	"petbots.fbbdev.it/dotmtxbot/log"
+)
+
+// wizardStep is a question asked by the /new wizard.
+type wizardStep int
+
+const (
+	wizardText wizardStep = iota
+	wizardSpeed
+	wizardWidth
+	wizardBlank
+	wizardColor
+	wizardDone
+)
+
+var wizardQuestions = [...]i18n.Message{
+	wizardText:  i18n.WizardText,
+	wizardSpeed: i18n.WizardSpeed,
+	wizardWidth: i18n.WizardWidth,
+	wizardBlank: i18n.WizardBlank,
+	wizardColor: i18n.WizardColor,
+}
+
+// wizardChoice is a button of the reply keyboard along with its value.
+type wizardChoice struct {
+	label i18n.Message
+	value string
+}
+
+var wizardChoices = [...][]wizardChoice{
+	wizardSpeed: {{i18n.SpeedSlow, "slow"}, {i18n.SpeedNormal, "normal"}, {i18n.SpeedFast, "fast"}},
+	wizardWidth: {{i18n.WidthHalf, "0.5"}, {i18n.WidthText, "1"}, {i18n.WidthDouble, "2"}},
+	wizardBlank: {{i18n.BlankNone, "0"}, {i18n.BlankHalf, "0.5"}, {i18n.BlankFull, "1"}},
+	wizardColor: {
+		{i18n.ColorAmber, "amber"}, {i18n.ColorRed, "red"}, {i18n.ColorGreen, "green"},
+		{i18n.ColorBlue, "blue"}, {i18n.ColorWhite, "white"},
+	},
+}
+
+// wizardTimeout is how long a wizard waits for an answer
+const wizardTimeout = 10 * time.Minute
+
+// wizard is the state of a /new conversation.
+type wizard struct {
+	step wizardStep
+	lang string
+
+	text   string
+	styles []dotmtx.StyleSpan
+	// speed, width and blank as typed by the user
+	params [3]string
+	color  string
+
+	expires time.Time
+}
+
+// options returns the options chosen so far, with defaults
+// for the questions still to be answered.
+func (wz *wizard) options() (dotmtx.Options, error) {
+	opts := dotmtx.Options{Styles: wz.styles}
+	if opts.Set("color", wz.color) != nil {
+		return opts, errInvalidOption
+	}
+
+	return finishQuery(opts, wz.params[:], wz.text)
+}
+
+// answer records the answer to the current question and moves on
+// to the next one; invalid answers leave the wizard unchanged.
+func (wz *wizard) answer(message *tgbotapi.Message) error {
+	next := *wz
+
+	if next.step == wizardText {
+		next.text, next.styles = messageText(message)
+		if strings.TrimSpace(next.text) == "" {
+			return errNotEnoughParams
+		}
+	} else {
+		value := strings.TrimSpace(message.Text)
+		for _, choice := range wizardChoices[next.step] {
+			if value == i18n.Text(next.lang, choice.label) {
+				value = choice.value
+				break
+			}
+		}
+
+		switch next.step {
+		case wizardSpeed, wizardWidth, wizardBlank:
+			next.params[next.step-wizardSpeed] = value
+		case wizardColor:
+			next.color = value
+		}
+	}
+
+	if _, err := next.options(); err != nil {
+		return err
+	}
+
+	next.step++
+	*wz = next
+	return nil
+}
+
+// keyboard returns the reply keyboard for the current question.
+func (wz *wizard) keyboard() interface{} {
+	choices := wizardChoices[wz.step]
+	if len(choices) == 0 {
+		return tgbotapi.NewRemoveKeyboard(true)
+	}
+
+	var row []tgbotapi.KeyboardButton
+	for _, choice := range choices {
+		row = append(row, tgbotapi.NewKeyboardButton(i18n.Text(wz.lang, choice.label)))
+	}
+
+	keyboard := tgbotapi.NewOneTimeReplyKeyboard(row)
+	keyboard.ResizeKeyboard = true
+
+	return keyboard
+}
+
+// wizardStore keeps in memory the wizards in progress, by chat.
+type wizardStore struct {
+	mu      sync.Mutex
+	wizards map[int64]wizard
+}
+
+var wizards = wizardStore{wizards: map[int64]wizard{}}
+
+func (ws *wizardStore) put(chatID int64, wz wizard) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	wz.expires = time.Now().Add(wizardTimeout)
+	ws.wizards[chatID] = wz
+}
+
+func (ws *wizardStore) get(chatID int64) (wizard, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	wz, ok := ws.wizards[chatID]
+	if !ok || time.Now().After(wz.expires) {
+		return wz, false
+	}
+
+	return wz, true
+}
+
+func (ws *wizardStore) remove(chatID int64) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	_, ok := ws.wizards[chatID]
+	delete(ws.wizards, chatID)
+	return ok
+}
+
+// expire removes inactive wizards at the given interval, forever.
+func (ws *wizardStore) expire(interval time.Duration) {
+	for now := range time.Tick(interval) {
+		ws.mu.Lock()
+		for chatID, wz := range ws.wizards {
+			if now.After(wz.expires) {
+				delete(ws.wizards, chatID)
+			}
+		}
+		ws.mu.Unlock()
+	}
+}
+
+// askWizardQuestion sends the current question, along with
+// a preview of the animation once the text is known.
+func askWizardQuestion(bot *tgbotapi.BotAPI, chatID int64, wz *wizard, prefix string) {
+	question := i18n.Text(wz.lang, wizardQuestions[wz.step])
+	if prefix != "" {
+		question = prefix + "\n\n" + question
+	}
+
+	var msg tgbotapi.Chattable
+
+	if opts, err := wz.options(); wz.step > wizardText && err == nil {
+		animation := tgbotapi.NewAnimation(chatID, tgbotapi.FileURL(renderURL(withErrorLang(opts, wz.lang))))
+		animation.Caption = question
+		animation.ReplyMarkup = wz.keyboard()
+		msg = animation
+	} else {
+		text := tgbotapi.NewMessage(chatID, question)
+		text.ReplyMarkup = wz.keyboard()
+		msg = text
+	}
+
+	if _, err := bot.Send(msg); err != nil {
+		log.ErrorLogger.Print("tgbotapi: ", err)
+		log.WarningLogger.Printf("could not send wizard question (chat_id=%v, step=%v)", chatID, wz.step)
+	}
+}
+
+func handleNew(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	lang := userLang(update)
+
+	if !update.Message.Chat.IsPrivate() {
+		sendMessage(bot, update.Message, i18n.Text(lang, i18n.WizardPrivate))
+		return
+	}
+
+	wz := wizard{
+		step:   wizardText,
+		lang:   lang,
+		params: [3]string{"normal", "1", "1"},
+		color:  dotmtx.ColorAmber.String(),
+	}
+
+	wizards.put(update.Message.Chat.ID, wz)
+	askWizardQuestion(bot, update.Message.Chat.ID, &wz, "")
+}
+
+func handleCancel(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	if !wizards.remove(update.Message.Chat.ID) {
+		handleUnknownCommand(bot, update)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, i18n.Text(userLang(update), i18n.WizardCancelled))
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+
+	if _, err := bot.Send(msg); err != nil {
+		log.ErrorLogger.Print("tgbotapi: ", err)
+		log.WarningLogger.Printf("could not send message (chat_id=%v)", msg.ChatID)
+	}
+}
+
+// handleWizardMessage feeds a message sent in a private chat
+// to the wizard in progress there, if any.
+func handleWizardMessage(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+
+	wz, ok := wizards.get(chatID)
+	if !ok {
+		return
+	}
+
+	if err := wz.answer(update.Message); err != nil {
+		wizards.put(chatID, wz)
+		askWizardQuestion(bot, chatID, &wz, errorText(err, wz.lang))
+		return
+	}
+
+	if wz.step < wizardDone {
+		wizards.put(chatID, wz)
+		askWizardQuestion(bot, chatID, &wz, "")
+		return
+	}
+
+	wizards.remove(chatID)
+
+	msg := tgbotapi.NewMessage(chatID, i18n.Text(wz.lang, i18n.WizardDone))
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+
+	if _, err := bot.Send(msg); err != nil {
+		log.ErrorLogger.Print("tgbotapi: ", err)
+		log.WarningLogger.Printf("could not send message (chat_id=%v)", msg.ChatID)
+	}
+
+	opts, _ := wz.options()
+	sendRender(bot, update, opts, wz.lang)
+}